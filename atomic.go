@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes a file by rendering it into a temporary file in the same
+// directory, syncing it to disk, and renaming it into place, so readers see either the
+// previous contents or the complete new contents, never a partial file. If anything
+// fails, the existing file is left untouched. If path is a symlink, its target is replaced.
+func writeFileAtomic(path string, perm os.FileMode, write func(io.Writer) error) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return WrapFileIO(err, fmt.Sprintf("failed to create temporary file for %s", path))
+	}
+	tmpName := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	buf := bufio.NewWriter(tmp)
+	if err := write(buf); err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return WrapFileIO(err, fmt.Sprintf("failed to write %s", path))
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return WrapFileIO(err, fmt.Sprintf("failed to set permissions of %s", path))
+	}
+	if err := tmp.Sync(); err != nil {
+		return WrapFileIO(err, fmt.Sprintf("failed to sync %s", path))
+	}
+	if err := tmp.Close(); err != nil {
+		return WrapFileIO(err, fmt.Sprintf("failed to write %s", path))
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return WrapFileIO(err, fmt.Sprintf("failed to replace %s", path))
+	}
+	committed = true
+
+	// Sync the directory so the rename itself survives a crash; not all platforms
+	// support this, so failures are ignored
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+)
+
+// commentsConcurrency bounds the number of photos' comments fetched at once.
+const commentsConcurrency = 4
+
+// maxItemComments is the number of most recent comments appended to each item's description.
+const maxItemComments = 5
+
+// fetchPhotoComments populates the Comments field of each photo, making up to
+// commentsConcurrency requests at a time. Photos whose comments can't be fetched
+// are left without them.
+func fetchPhotoComments(client *FlickrClient, photos []FlickrPhoto) {
+	forEachConcurrently(len(photos), commentsConcurrency, func(i int) {
+		comments, err := client.GetPhotoComments(photos[i].ID)
+		if err != nil {
+			client.logger.Warn("Failed to get comments", "photo_id", photos[i].ID, "error", err)
+			return
+		}
+		photos[i].Comments = comments
+	})
+}
+
+// appendItemComments appends each photo's most recent comments to its item's description.
+func appendItemComments(feed *RSSFeed) {
+	for i := range feed.Items {
+		if comments := generateItemComments(feed.Photos[i], feed.Items[i].Link); comments != "" {
+			feed.Items[i].Description += "<br/><br/>" + comments
+		}
+	}
+}
+
+// generateItemComments renders the photo's most recent comments, oldest first, noting
+// how many earlier comments there are on Flickr.
+func generateItemComments(photo FlickrPhoto, photoURL string) string {
+	comments := photo.Comments
+	if len(comments) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("<b>Comments:</b>")
+	if earlier := len(comments) - maxItemComments; earlier > 0 {
+		noun := "comments"
+		if earlier == 1 {
+			noun = "comment"
+		}
+		b.WriteString(fmt.Sprintf(` <a href="%s">%d earlier %s on Flickr</a>`, html.EscapeString(photoURL), earlier, noun))
+		comments = comments[earlier:]
+	}
+	for _, comment := range comments {
+		b.WriteString("<br/>")
+		b.WriteString(fmt.Sprintf("<b>%s</b>", html.EscapeString(comment.AuthorDisplayName())))
+		if date := comment.Date(); !date.IsZero() {
+			b.WriteString(fmt.Sprintf(" (%s)", date.UTC().Format("January 2, 2006")))
+		}
+		b.WriteString(": ")
+		b.WriteString(SanitizeHTML(comment.Content))
+	}
+	return b.String()
+}
+
+// BuildCommentsFeed returns a feed of the comments on a feed's photos, newest first,
+// with each comment an item linking to it on Flickr.
+func BuildCommentsFeed(feed *RSSFeed) *RSSFeed {
+	comments := &RSSFeed{
+		Title:       "Comments on " + feed.Title,
+		Link:        feed.Link,
+		Description: "Comments on " + strings.TrimPrefix(feed.Description, "Latest "),
+		Author:      feed.Author,
+		Image:       feed.Image,
+		Private:     feed.Private,
+	}
+
+	type commentRef struct {
+		photo   int
+		comment FlickrComment
+	}
+	var refs []commentRef
+	for i, photo := range feed.Photos {
+		for _, comment := range photo.Comments {
+			refs = append(refs, commentRef{i, comment})
+		}
+	}
+	sort.SliceStable(refs, func(i, j int) bool {
+		return refs[i].comment.Date().After(refs[j].comment.Date())
+	})
+
+	for _, ref := range refs {
+		photo := feed.Photos[ref.photo]
+		photoItem := feed.Items[ref.photo]
+		comment := ref.comment
+
+		photoTitle := firstNonEmpty(photo.Title, "Untitled")
+		desc := fmt.Sprintf(`<a href="%s"><img src="%s" alt="%s" /></a><br/><br/>%s`,
+			html.EscapeString(photoItem.Link), html.EscapeString(photoThumbnailURL(photo)),
+			html.EscapeString(photoTitle), SanitizeHTML(comment.Content))
+
+		item := RSSItem{
+			Title:       fmt.Sprintf("%s on %s", comment.AuthorDisplayName(), photoTitle),
+			Link:        firstNonEmpty(comment.Permalink, photoItem.Link),
+			Description: desc,
+			Creator:     comment.AuthorDisplayName(),
+			GUID:        comment.ID,
+		}
+		if date := comment.Date(); !date.IsZero() {
+			item.PubDate = date.Format(time.RFC1123Z)
+		}
+		comments.Items = append(comments.Items, item)
+		comments.Photos = append(comments.Photos, photo)
+	}
+
+	return comments
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"os"
 
@@ -12,8 +14,13 @@ type Credentials struct {
 	APISecret        string `yaml:"api_secret"`
 	OAuthToken       string `yaml:"oauth_token"`
 	OAuthTokenSecret string `yaml:"oauth_token_secret"`
+	// FeedTokenSecret is a random secret from which tokenized feeds' tokens are derived.
+	FeedTokenSecret string `yaml:"feed_token_secret,omitempty"`
 }
 
+// feedTokenSecretBytes is the number of random bytes in a generated feed token secret.
+const feedTokenSecretBytes = 32
+
 func loadCredsIfProvided() (*Credentials, error) {
 	if credsFile == "" {
 		return &Credentials{
@@ -21,6 +28,7 @@ func loadCredsIfProvided() (*Credentials, error) {
 			APISecret:        apiSecret,
 			OAuthToken:       oauthToken,
 			OAuthTokenSecret: oauthSecret,
+			FeedTokenSecret:  feedTokenSecret,
 		}, nil
 	}
 
@@ -47,6 +55,9 @@ func loadCredsIfProvided() (*Credentials, error) {
 	if oauthSecret != "" {
 		creds.OAuthTokenSecret = oauthSecret
 	}
+	if feedTokenSecret != "" {
+		creds.FeedTokenSecret = feedTokenSecret
+	}
 
 	return &creds, nil
 }
@@ -77,3 +88,11 @@ func (c *Credentials) Validate() error {
 func (c *Credentials) HasOAuth() bool {
 	return c.OAuthToken != "" && c.OAuthTokenSecret != ""
 }
+
+func newFeedTokenSecret() (string, error) {
+	secret := make([]byte, feedTokenSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate feed token secret: %w", err)
+	}
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	defaultDaemonWorkers  = 2
+	defaultDaemonInterval = 15 * time.Minute
+	defaultDaemonJitter   = 0.1
+	defaultDaemonCount    = 20
+
+	// minDaemonInterval keeps feeds from polling the Flickr API too aggressively.
+	minDaemonInterval = time.Minute
+	// serverErrorRetry is the first retry delay after a Flickr server error; it
+	// doubles with each consecutive failure, up to the feed's interval.
+	serverErrorRetry = time.Minute
+	// maxFailureBackoff caps the delay after repeated failures other than server
+	// errors, unless the feed's own interval is longer.
+	maxFailureBackoff = 6 * time.Hour
+)
+
+// DaemonConfig is the configuration file read by the daemon command.
+type DaemonConfig struct {
+	// Workers is the number of feeds that may be generated at once.
+	Workers int `yaml:"workers"`
+	// Interval is the default time between generations of each feed.
+	Interval time.Duration `yaml:"interval"`
+	// Jitter randomly varies each feed's interval by up to this fraction of it,
+	// so feeds don't all hit the Flickr API at once. It's a pointer so that an
+	// explicit 0, which disables jitter, can be told apart from a missing value.
+	Jitter *float64 `yaml:"jitter"`
+	// MetricsListen is the address to serve Prometheus metrics on, at /metrics.
+	MetricsListen string `yaml:"metrics_listen"`
+
+	Feeds []DaemonFeed `yaml:"feeds"`
+}
+
+// DaemonFeed is a feed generated by the daemon: a FeedConfig plus its schedule.
+type DaemonFeed struct {
+	FeedConfig `yaml:",inline"`
+
+	// Interval overrides the default interval for this feed.
+	Interval time.Duration `yaml:"interval"`
+	// FiltersFile is a YAML file of filter rules, merged with the feed's filters.
+	FiltersFile string `yaml:"filters_file"`
+}
+
+// LoadDaemonConfig reads and validates a daemon configuration file, filling in defaults.
+func LoadDaemonConfig(path string, creds *Credentials) (*DaemonConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, WrapFileIO(err, fmt.Sprintf("failed to read daemon config file %s", path))
+	}
+
+	var cfg DaemonConfig
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, WrapInputs(err, fmt.Sprintf("failed to parse daemon config file %s", path))
+	}
+
+	if cfg.Workers == 0 {
+		cfg.Workers = defaultDaemonWorkers
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = defaultDaemonInterval
+	}
+	if cfg.Jitter == nil {
+		jitter := defaultDaemonJitter
+		cfg.Jitter = &jitter
+	}
+	if cfg.Workers < 0 {
+		return nil, NewInputs("workers must be positive")
+	}
+	if *cfg.Jitter < 0 || *cfg.Jitter > 1 {
+		return nil, NewInputs("jitter must be between 0 and 1")
+	}
+	if len(cfg.Feeds) == 0 {
+		return nil, NewInputs(fmt.Sprintf("no feeds configured in %s", path))
+	}
+
+	names := make(map[string]bool)
+	for i := range cfg.Feeds {
+		feed := &cfg.Feeds[i]
+		if feed.Output == "" {
+			return nil, NewInputs(fmt.Sprintf("feed %d has no output file", i+1))
+		}
+		if feed.Interval == 0 {
+			feed.Interval = cfg.Interval
+		}
+		if feed.Interval < minDaemonInterval {
+			return nil, NewInputs(fmt.Sprintf("feed %s: interval must be at least %s", feed.FeedName(), minDaemonInterval))
+		}
+		if feed.Count == 0 {
+			feed.Count = defaultDaemonCount
+		}
+
+		if feed.FiltersFile != "" {
+			fileRules, err := LoadFilterRules(feed.FiltersFile)
+			if err != nil {
+				return nil, err
+			}
+			feed.Filters = fileRules.Merge(feed.Filters)
+		}
+
+		feed.FeedConfig, err = ApplyFeedToken(creds, feed.FeedConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		name := feed.FeedName()
+		if names[name] {
+			return nil, NewInputs(fmt.Sprintf("duplicate feed name %s; give each feed a unique name or output", name))
+		}
+		names[name] = true
+	}
+
+	return &cfg, nil
+}
+
+// runDaemon regenerates each configured feed on its own schedule until interrupted.
+// On SIGHUP it reloads the configuration and credentials, resetting every feed's
+// schedule; if the new configuration is invalid, it keeps running the old one.
+func runDaemon(_ *cobra.Command, _ []string) error {
+	if daemonConfigFile == "" {
+		return NewUsage("--config is required")
+	}
+
+	load := func() (*DaemonConfig, *Credentials, error) {
+		creds, err := loadCredsIfProvided()
+		if err != nil {
+			return nil, nil, WrapInputs(err, "failed to load credentials")
+		}
+		if err := creds.Validate(); err != nil {
+			return nil, nil, WrapInputs(err, "invalid credentials")
+		}
+		cfg, err := LoadDaemonConfig(daemonConfigFile, creds)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cfg, creds, nil
+	}
+
+	cfg, creds, err := load()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	// The metrics listener is only read at startup; changing it requires a restart
+	var server *http.Server
+	if cfg.MetricsListen != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics)
+		server = &http.Server{Addr: cfg.MetricsListen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			slog.Info("Serving metrics", "addr", cfg.MetricsListen)
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Metrics server failed", "addr", cfg.MetricsListen, "error", err)
+			}
+		}()
+	}
+
+	for {
+		slog.Info("Starting daemon", "feeds", len(cfg.Feeds), "workers", cfg.Workers)
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			newScheduler(cfg, creds).run(runCtx)
+			close(done)
+		}()
+
+		reloaded := false
+		for !reloaded {
+			select {
+			case <-ctx.Done():
+				slog.Info("Shutting down; waiting for running feeds to finish")
+				cancel()
+				<-done
+				if server != nil {
+					shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+					server.Shutdown(shutdownCtx)
+					cancelShutdown()
+				}
+				return nil
+			case <-hup:
+				newCfg, newCreds, err := load()
+				if err != nil {
+					slog.Error("Failed to reload configuration; continuing with the previous configuration", "error_class", ErrorClass(err), "error", err)
+					continue
+				}
+				slog.Info("Reloading configuration; waiting for running feeds to finish")
+				cancel()
+				<-done
+				cfg, creds = newCfg, newCreds
+				reloaded = true
+			}
+		}
+	}
+}
+
+// scheduler runs a DaemonConfig's feeds on their schedules, using a fixed pool of workers.
+type scheduler struct {
+	cfg   *DaemonConfig
+	creds *Credentials
+}
+
+type feedJob struct {
+	feed DaemonFeed
+	done chan error
+}
+
+func newScheduler(cfg *DaemonConfig, creds *Credentials) *scheduler {
+	return &scheduler{cfg: cfg, creds: creds}
+}
+
+// run schedules feeds until ctx is canceled, then waits for running feeds to finish.
+func (s *scheduler) run(ctx context.Context) {
+	jobs := make(chan feedJob)
+
+	workersDone := make(chan struct{})
+	go func() {
+		forEachConcurrently(s.cfg.Workers, s.cfg.Workers, func(int) {
+			for job := range jobs {
+				_, err := GenerateFeed(s.creds, job.feed.FeedConfig)
+				job.done <- err
+			}
+		})
+		close(workersDone)
+	}()
+
+	forEachConcurrently(len(s.cfg.Feeds), len(s.cfg.Feeds), func(i int) {
+		s.scheduleFeed(ctx, s.cfg.Feeds[i], jobs)
+	})
+	close(jobs)
+	<-workersDone
+}
+
+// scheduleFeed repeatedly queues the feed for generation, waiting between runs
+// according to the outcome of the previous one, until ctx is canceled.
+func (s *scheduler) scheduleFeed(ctx context.Context, feed DaemonFeed, jobs chan<- feedJob) {
+	logger := feed.Logger()
+
+	// Stagger the first runs across the jitter window
+	delay := time.Duration(rand.Float64() * *s.cfg.Jitter * float64(feed.Interval))
+	failures := 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		job := feedJob{feed: feed, done: make(chan error, 1)}
+		select {
+		case jobs <- job:
+		case <-ctx.Done():
+			return
+		}
+		err := <-job.done
+
+		// The feed was written; only notifying others of the change failed, which
+		// GenerateFeed has already logged, and retrying sooner wouldn't help
+		var notifyErr *NotifyError
+		if errors.As(err, &notifyErr) {
+			err = nil
+		}
+
+		if err == nil {
+			failures = 0
+			delay = s.jitter(feed.Interval)
+			logger.Info("Generated feed", "next_run", delay.Round(time.Second))
+			continue
+		}
+
+		failures++
+		class := ErrorClass(err)
+		retry, ok := retryDelay(err, failures, feed.Interval)
+		if !ok {
+			logger.Error("Feed failed; pausing it until the configuration is reloaded with SIGHUP",
+				"error_class", class, "error", err)
+			<-ctx.Done()
+			return
+		}
+		delay = s.jitter(retry)
+		logger.Warn("Feed failed; retrying later", "error_class", class, "error", err,
+			"failures", failures, "next_run", delay.Round(time.Second))
+	}
+}
+
+// retryDelay returns how long to wait before retrying a feed after its given number
+// of consecutive failures, the last with err. It returns false if the feed should be
+// paused instead, because retrying won't help until the credentials or configuration
+// are fixed.
+func retryDelay(err error, failures int, interval time.Duration) (time.Duration, bool) {
+	switch {
+	case errors.Is(err, ErrFlickrAuth), errors.Is(err, ErrUsage), errors.Is(err, ErrInputs):
+		return 0, false
+	case errors.Is(err, ErrFlickrServer):
+		return backoff(serverErrorRetry, failures, interval), true
+	default:
+		return backoff(interval, failures, max(maxFailureBackoff, interval)), true
+	}
+}
+
+// backoff returns base doubled for each failure after the first, up to limit.
+func backoff(base time.Duration, failures int, limit time.Duration) time.Duration {
+	d := base
+	for i := 1; i < failures && d < limit; i++ {
+		d *= 2
+	}
+	return min(d, limit)
+}
+
+// jitter randomly varies d by up to the configured jitter fraction in either direction.
+func (s *scheduler) jitter(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * (1 + *s.cfg.Jitter*(2*rand.Float64()-1)))
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     time.Duration
+		failures int
+		limit    time.Duration
+		want     time.Duration
+	}{
+		{"first failure", time.Minute, 1, time.Hour, time.Minute},
+		{"second failure", time.Minute, 2, time.Hour, 2 * time.Minute},
+		{"fifth failure", time.Minute, 5, time.Hour, 16 * time.Minute},
+		{"capped", time.Minute, 7, time.Hour, time.Hour},
+		{"many failures", time.Minute, 1000, time.Hour, time.Hour},
+		{"base above limit", 2 * time.Hour, 1, time.Hour, time.Hour},
+		{"base equals limit", 15 * time.Minute, 3, 15 * time.Minute, 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := backoff(tt.base, tt.failures, tt.limit); got != tt.want {
+				t.Errorf("backoff(%s, %d, %s) = %s, want %s", tt.base, tt.failures, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	serverErr := WrapFlickrAPI(ClassifyFlickrError(502, 0, "API request failed with status 502"), "failed to fetch photos")
+	otherErr := WrapFileIO(errors.New("disk full"), "failed to write feed")
+
+	tests := []struct {
+		name      string
+		err       error
+		failures  int
+		interval  time.Duration
+		want      time.Duration
+		wantRetry bool
+	}{
+		{"auth error pauses", ClassifyFlickrError(200, 98, "Invalid auth token"), 1, 15 * time.Minute, 0, false},
+		{"usage error pauses", NewUsage("--tokenize requires a feed token secret"), 1, 15 * time.Minute, 0, false},
+		{"unknown user pauses", WrapFlickrAPI(ClassifyFlickrError(200, 1, "User not found"), "failed to look up user"), 1, 15 * time.Minute, 0, false},
+		{"server error retries after a minute", serverErr, 1, 15 * time.Minute, time.Minute, true},
+		{"server error backs off", serverErr, 3, 15 * time.Minute, 4 * time.Minute, true},
+		{"server error capped at interval", serverErr, 10, 15 * time.Minute, 15 * time.Minute, true},
+		{"other error retries after interval", otherErr, 1, 15 * time.Minute, 15 * time.Minute, true},
+		{"other error backs off", otherErr, 3, 15 * time.Minute, time.Hour, true},
+		{"other error capped", otherErr, 10, 15 * time.Minute, maxFailureBackoff, true},
+		{"other error cap below interval", otherErr, 3, 24 * time.Hour, 24 * time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, retry := retryDelay(tt.err, tt.failures, tt.interval)
+			if retry != tt.wantRetry || got != tt.want {
+				t.Errorf("retryDelay(%v, %d, %s) = %s, %v; want %s, %v",
+					tt.err, tt.failures, tt.interval, got, retry, tt.want, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestLoadDaemonConfigJitter(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    float64
+		wantErr bool
+	}{
+		{"default", "", defaultDaemonJitter, false},
+		{"explicit zero disables", "jitter: 0", 0, false},
+		{"explicit value", "jitter: 0.25", 0.25, false},
+		{"too large", "jitter: 1.5", 0, true},
+		{"negative", "jitter: -0.1", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "feeds.yaml")
+			config := fmt.Sprintf("%s\nfeeds:\n  - users: [alice]\n    output: %s\n", tt.line, filepath.Join(t.TempDir(), "alice.xml"))
+			if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := LoadDaemonConfig(path, &Credentials{APIKey: "key", APISecret: "secret"})
+			if tt.wantErr {
+				if !errors.Is(err, ErrInputs) {
+					t.Errorf("got error %v, want an inputs error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadDaemonConfig: %v", err)
+			}
+			if cfg.Jitter == nil {
+				t.Fatal("Jitter is unset")
+			}
+			if *cfg.Jitter != tt.want {
+				t.Errorf("Jitter = %v, want %v", *cfg.Jitter, tt.want)
+			}
+		})
+	}
+}
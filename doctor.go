@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// maxClockSkew is how far the local clock may differ from Flickr's before the doctor
+// command reports a problem. Flickr rejects OAuth requests with stale timestamps.
+const maxClockSkew = 5 * time.Minute
+
+// doctorCheck is one of the checks run by the doctor command. run returns a short
+// description of what was found, and an error if the check failed; a check that
+// doesn't apply returns a skipCheckError.
+type doctorCheck struct {
+	name string
+	run  func() (string, error)
+}
+
+type skipCheckError struct{ reason string }
+
+func (e skipCheckError) Error() string { return e.reason }
+
+func runDoctor(_ *cobra.Command, _ []string) error {
+	var creds *Credentials
+
+	checks := []doctorCheck{
+		{"credentials", func() (string, error) {
+			loaded, err := loadCredsIfProvided()
+			if err != nil {
+				return "", WrapInputs(err, "failed to load credentials")
+			}
+			if err := loaded.Validate(); err != nil {
+				return "", WrapInputs(err, "invalid credentials")
+			}
+			creds = loaded
+			if creds.HasOAuth() {
+				return "API key, secret, and OAuth token present", nil
+			}
+			return "API key and secret present; no OAuth token", nil
+		}},
+		{"api key", func() (string, error) {
+			if creds == nil {
+				return "", skipCheckError{"no valid credentials"}
+			}
+			// Call without OAuth, so this checks the API key alone
+			client := NewFlickrClient(&Credentials{APIKey: creds.APIKey, APISecret: creds.APISecret})
+			if err := client.Echo(); err != nil {
+				return "", err
+			}
+			return "flickr.test.echo succeeded", nil
+		}},
+		{"oauth", func() (string, error) {
+			if creds == nil {
+				return "", skipCheckError{"no valid credentials"}
+			}
+			if !creds.HasOAuth() {
+				return "", skipCheckError{"no OAuth token"}
+			}
+			userID, username, err := NewFlickrClient(creds).TestLogin()
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("authenticated as %s (%s)", username, userID), nil
+		}},
+		{"clock", func() (string, error) {
+			serverTime, err := NewFlickrClient(&Credentials{}).ServerTime()
+			if err != nil {
+				return "", err
+			}
+			// The Date header has one-second resolution
+			skew := time.Since(serverTime).Truncate(time.Second)
+			if skew > maxClockSkew || skew < -maxClockSkew {
+				return "", NewInputs(fmt.Sprintf("local clock differs from Flickr's by %s; OAuth requests will fail until it's corrected", skew))
+			}
+			return fmt.Sprintf("local clock is within %s of Flickr's", skew.Abs()+time.Second), nil
+		}},
+		{"output", func() (string, error) {
+			if output == "" {
+				return "", skipCheckError{"no --output given"}
+			}
+			if err := checkOutputWritable(output); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%s is writable", output), nil
+		}},
+	}
+
+	var firstErr error
+	failed := 0
+	for _, check := range checks {
+		detail, err := check.run()
+		switch err.(type) {
+		case nil:
+			fmt.Printf("PASS  %-12s %s\n", check.name, detail)
+		case skipCheckError:
+			fmt.Printf("SKIP  %-12s %s\n", check.name, err)
+		default:
+			fmt.Printf("FAIL  %-12s %s\n", check.name, err)
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if firstErr != nil {
+		return fmt.Errorf("%d of %d checks failed: %w", failed, len(checks), firstErr)
+	}
+	return nil
+}
+
+// checkOutputWritable verifies that a feed can be written to the given path: that a
+// new file can be created in its directory and, if it exists, that it can be opened
+// for writing.
+func checkOutputWritable(path string) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, ".flickr-rss-doctor-*")
+	if err != nil {
+		return WrapFileIO(err, fmt.Sprintf("can't create files in %s", dir))
+	}
+	tmp.Close()
+	os.Remove(tmp.Name())
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return WrapFileIO(err, fmt.Sprintf("can't write to %s", path))
+	}
+	file.Close()
+	return nil
+}
@@ -0,0 +1,487 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxDigestSeen bounds how many photos the digest state file remembers; it only
+	// needs to cover the photos that can still appear in a source's latest photos.
+	maxDigestSeen = 1000
+	// thumbnailConcurrency bounds the number of thumbnails downloaded at once.
+	thumbnailConcurrency = 4
+	// thumbnailTimeout bounds how long downloading each thumbnail may take.
+	thumbnailTimeout = 30 * time.Second
+	// smtpTimeout bounds how long connecting to the SMTP server may take.
+	smtpTimeout = 30 * time.Second
+)
+
+// DigestConfig describes an email digest: the feed whose new photos it lists, and
+// how it's delivered.
+type DigestConfig struct {
+	Feed FeedConfig
+
+	// State is a file recording the photos already sent, so each digest lists only new ones.
+	State string
+	// Subject defaults to a summary of the feed's title and the number of new photos.
+	Subject string
+	From    string
+	To      []string
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUser     string
+	SMTPPassword string
+
+	// EML writes the message to this file instead of sending it.
+	EML string
+	// AllowPrivate permits emailing a digest that can include non-public photos.
+	AllowPrivate bool
+}
+
+// digestState is the JSON state file of an email digest.
+type digestState struct {
+	// Seen lists the GUIDs of photos already sent, most recent first.
+	Seen    []string  `json:"seen"`
+	LastRun time.Time `json:"last_run"`
+}
+
+// digestPhoto is a photo as rendered in a digest.
+type digestPhoto struct {
+	Title string
+	Link  string
+	Owner string
+	Date  string
+	// ImageSrc is a cid: URL for an attached thumbnail, which html/template would
+	// otherwise reject as unsafe, or a Flickr image URL
+	ImageSrc template.URL
+	Video    bool
+	Body     template.HTML
+
+	thumbnail   []byte
+	contentID   string
+	contentType string
+}
+
+// SendDigest builds the feed and emails a digest of the photos that weren't in the
+// previous digest, recording them in the state file once it's sent. If there are no
+// new photos, nothing is sent. It returns the number of photos in the digest.
+func SendDigest(creds *Credentials, cfg DigestConfig) (int, error) {
+	logger := cfg.Feed.Logger()
+
+	state, err := loadDigestState(cfg.State)
+	if err != nil {
+		return 0, err
+	}
+
+	feed, err := BuildFeed(creds, cfg.Feed)
+	if err != nil {
+		return 0, err
+	}
+
+	// Like a private feed, a digest of non-public photos is only written readable by the
+	// current user, and it's only emailed, beyond the user's control, if they opt in
+	perm := os.FileMode(0644)
+	if feed.Private {
+		if cfg.EML == "" && !cfg.AllowPrivate {
+			return 0, NewUsage("the digest can include non-public photos; use --allow-private to email them anyway")
+		}
+		for _, path := range []string{cfg.EML, cfg.State} {
+			if path == "" {
+				continue
+			}
+			if err := checkPrivateOutput(path); err != nil {
+				return 0, err
+			}
+		}
+		perm = 0600
+	}
+
+	seen := make(map[string]bool, len(state.Seen))
+	for _, guid := range state.Seen {
+		seen[guid] = true
+	}
+	indexes := newItemIndexes(feed, seen)
+	if len(indexes) == 0 {
+		logger.Info("No new photos since the last digest; not sending one")
+		return 0, nil
+	}
+
+	photos := make([]digestPhoto, len(indexes))
+	for n, i := range indexes {
+		photos[n] = newDigestPhoto(feed.Items[i], feed.Photos[i], feed.Extras)
+	}
+	downloadThumbnails(photos, feed.Photos, indexes)
+
+	subject := cfg.Subject
+	if subject == "" {
+		noun := "photos"
+		if len(photos) == 1 {
+			noun = "photo"
+		}
+		subject = fmt.Sprintf("%s: %d new %s", feed.Title, len(photos), noun)
+	}
+
+	msg, err := buildDigestMessage(cfg, subject, feed, photos)
+	if err != nil {
+		return 0, err
+	}
+
+	if cfg.EML != "" {
+		err = writeFileAtomic(cfg.EML, perm, func(w io.Writer) error {
+			if _, err := w.Write(msg); err != nil {
+				return WrapFileIO(err, fmt.Sprintf("failed to write %s", cfg.EML))
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		logger.Info("Wrote email digest", "path", cfg.EML, "photos", len(photos))
+	} else {
+		if err := sendMail(cfg, msg); err != nil {
+			return 0, err
+		}
+		logger.Info("Sent email digest", "to", strings.Join(cfg.To, ", "), "photos", len(photos))
+	}
+
+	newSeen := make([]string, 0, len(indexes)+len(state.Seen))
+	for _, i := range indexes {
+		newSeen = append(newSeen, feed.Items[i].GUID)
+	}
+	state.Seen = append(newSeen, state.Seen...)
+	if len(state.Seen) > maxDigestSeen {
+		state.Seen = state.Seen[:maxDigestSeen]
+	}
+	state.LastRun = time.Now().UTC()
+	if err := saveDigestState(cfg.State, state, perm); err != nil {
+		return len(photos), err
+	}
+
+	return len(photos), nil
+}
+
+// loadDigestState reads a digest state file; a missing file is an empty state.
+func loadDigestState(path string) (digestState, error) {
+	var state digestState
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, WrapFileIO(err, fmt.Sprintf("failed to read digest state file %s", path))
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, WrapInputs(err, fmt.Sprintf("failed to parse digest state file %s", path))
+	}
+	return state, nil
+}
+
+func saveDigestState(path string, state digestState, perm os.FileMode) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode digest state: %w", err)
+	}
+	return writeFileAtomic(path, perm, func(w io.Writer) error {
+		if _, err := w.Write(append(data, '\n')); err != nil {
+			return WrapFileIO(err, fmt.Sprintf("failed to write digest state file %s", path))
+		}
+		return nil
+	})
+}
+
+// photoThumbnailURL returns the URL of a small (320px) version of the photo.
+func photoThumbnailURL(photo FlickrPhoto) string {
+	if photo.Server == "" || photo.Secret == "" {
+		return photo.URL
+	}
+	return photoSizeURL(photo, "n")
+}
+
+// photoSizeURL returns the URL of the photo at one of Flickr's size suffixes, such as
+// "n" (320px) or "z" (640px).
+func photoSizeURL(photo FlickrPhoto, suffix string) string {
+	return fmt.Sprintf("https://live.staticflickr.com/%s/%s_%s_%s.jpg", photo.Server, photo.ID, photo.Secret, suffix)
+}
+
+func newDigestPhoto(item RSSItem, photo FlickrPhoto, extras ItemExtras) digestPhoto {
+	var body strings.Builder
+	if photo.Description.Content != "" {
+		body.WriteString(SanitizeHTML(photo.Description.Content))
+	}
+	if details := generateItemDetails(photo, extras); details != "" {
+		if body.Len() > 0 {
+			body.WriteString("<br/><br/>")
+		}
+		body.WriteString(details)
+	}
+
+	p := digestPhoto{
+		Title:    firstNonEmpty(item.Title, "Untitled"),
+		Link:     item.Link,
+		Owner:    photo.OwnerDisplayName(),
+		Date:     formatItemDate(item),
+		ImageSrc: template.URL(photoThumbnailURL(photo)),
+		Video:    photo.IsVideo(),
+		// Both parts are sanitized or built from escaped values by the feed renderer
+		Body: template.HTML(body.String()),
+	}
+	return p
+}
+
+// downloadThumbnails fetches each photo's thumbnail so it can be embedded in the
+// message. Photos whose thumbnails can't be fetched keep linking to the remote image.
+func downloadThumbnails(photos []digestPhoto, flickrPhotos []FlickrPhoto, indexes []int) {
+	client := &http.Client{Timeout: thumbnailTimeout}
+	forEachConcurrently(len(photos), thumbnailConcurrency, func(n int) {
+		p := &photos[n]
+		id := flickrPhotos[indexes[n]].ID
+		if p.ImageSrc == "" {
+			return
+		}
+
+		data, contentType, err := downloadImage(client, string(p.ImageSrc))
+		if err != nil {
+			slog.Info("Failed to download thumbnail; linking to it instead", "photo_id", id, "error", err)
+			return
+		}
+		p.thumbnail = data
+		p.contentType = contentType
+		p.contentID = fmt.Sprintf("photo-%s@flickr-rss", id)
+		p.ImageSrc = template.URL("cid:" + p.contentID)
+	})
+}
+
+func downloadImage(client *http.Client, url string) ([]byte, string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, "", fmt.Errorf("unexpected content type %q", contentType)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, "", err
+	}
+	return data, contentType, nil
+}
+
+var digestTemplate = template.Must(template.New("digest").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Subject}}</title></head>
+<body style="font-family: -apple-system, Helvetica, Arial, sans-serif; color: #222; max-width: 640px; margin: 0 auto;">
+<h1 style="font-size: 20px;"><a href="{{.Link}}" style="color: #222;">{{.Title}}</a></h1>
+{{if .Description}}<p style="color: #666;">{{.Description}}</p>{{end}}
+{{range .Photos}}
+<div style="margin: 24px 0; padding-top: 16px; border-top: 1px solid #ddd;">
+<a href="{{.Link}}"><img src="{{.ImageSrc}}" alt="{{.Title}}" style="max-width: 320px; border: 0;"></a>
+<h2 style="font-size: 16px; margin: 8px 0 4px;"><a href="{{.Link}}" style="color: #222;">{{.Title}}</a>{{if .Video}} (video){{end}}</h2>
+<p style="color: #666; margin: 0 0 8px;">{{.Owner}}{{if .Date}} &middot; {{.Date}}{{end}}</p>
+{{if .Body}}<div style="font-size: 14px;">{{.Body}}</div>{{end}}
+</div>
+{{end}}
+</body>
+</html>
+`))
+
+// buildDigestMessage renders the digest as a MIME message: a plain text version, and
+// an HTML version with the downloaded thumbnails attached inline.
+func buildDigestMessage(cfg DigestConfig, subject string, feed *RSSFeed, photos []digestPhoto) ([]byte, error) {
+	var htmlBody bytes.Buffer
+	err := digestTemplate.Execute(&htmlBody, map[string]any{
+		"Subject":     subject,
+		"Title":       feed.Title,
+		"Link":        feed.Link,
+		"Description": feed.Description,
+		"Photos":      photos,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render email digest: %w", err)
+	}
+
+	var textBody strings.Builder
+	fmt.Fprintf(&textBody, "%s\n%s\n", feed.Title, feed.Link)
+	for _, p := range photos {
+		fmt.Fprintf(&textBody, "\n%s\n%s", p.Title, p.Owner)
+		if p.Date != "" {
+			fmt.Fprintf(&textBody, ", %s", p.Date)
+		}
+		fmt.Fprintf(&textBody, "\n%s\n", p.Link)
+	}
+
+	var msg bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&msg, "%s: %s\r\n", name, value)
+	}
+	header("From", cfg.From)
+	header("To", strings.Join(cfg.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@flickr-rss>", randomID()))
+	header("MIME-Version", "1.0")
+
+	alternative := multipart.NewWriter(&msg)
+	header("Content-Type", "multipart/alternative; boundary="+alternative.Boundary())
+	msg.WriteString("\r\n")
+
+	if err := writeQuotedPrintablePart(alternative, "text/plain; charset=utf-8", textBody.String()); err != nil {
+		return nil, err
+	}
+
+	relatedBoundary := multipart.NewWriter(nil).Boundary()
+	relatedPart, err := alternative.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"multipart/related; boundary=" + relatedBoundary},
+	})
+	if err != nil {
+		return nil, err
+	}
+	related := multipart.NewWriter(relatedPart)
+	if err := related.SetBoundary(relatedBoundary); err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintablePart(related, "text/html; charset=utf-8", htmlBody.String()); err != nil {
+		return nil, err
+	}
+	for _, p := range photos {
+		if p.thumbnail == nil {
+			continue
+		}
+		ext := ".jpg"
+		if exts, _ := mime.ExtensionsByType(p.contentType); len(exts) > 0 && p.contentType != "image/jpeg" {
+			ext = exts[0]
+		}
+		part, err := related.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-ID":                {"<" + p.contentID + ">"},
+			"Content-Disposition":       {fmt.Sprintf(`inline; filename="%s%s"`, strings.TrimSuffix(p.contentID, "@flickr-rss"), ext)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoded := base64.StdEncoding.EncodeToString(p.thumbnail)
+		for len(encoded) > 76 {
+			io.WriteString(part, encoded[:76]+"\r\n")
+			encoded = encoded[76:]
+		}
+		io.WriteString(part, encoded+"\r\n")
+	}
+	if err := related.Close(); err != nil {
+		return nil, err
+	}
+	if err := alternative.Close(); err != nil {
+		return nil, err
+	}
+
+	return msg.Bytes(), nil
+}
+
+func writeQuotedPrintablePart(w *multipart.Writer, contentType, body string) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := io.WriteString(qp, body); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// sendMail delivers the message over SMTP. Port 465 uses implicit TLS; otherwise the
+// connection is upgraded with STARTTLS when the server supports it, which is required
+// to authenticate to anything but localhost.
+func sendMail(cfg DigestConfig, msg []byte) error {
+	addr := net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort))
+	tlsConfig := &tls.Config{ServerName: cfg.SMTPHost}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	if cfg.SMTPPort == 465 {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server %s: %w", addr, err)
+	}
+
+	client, err := smtp.NewClient(conn, cfg.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to SMTP server %s: %w", addr, err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && cfg.SMTPPort != 465 {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("SMTP STARTTLS failed: %w", err)
+		}
+	}
+	if cfg.SMTPUser != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.SMTPUser, cfg.SMTPPassword, cfg.SMTPHost)); err != nil {
+			return WrapInputs(err, "SMTP authentication failed")
+		}
+	}
+
+	// Addresses were validated by the caller
+	from, _ := mail.ParseAddress(cfg.From)
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("SMTP server rejected sender %s: %w", from.Address, err)
+	}
+	for _, to := range cfg.To {
+		rcpt, _ := mail.ParseAddress(to)
+		if err := client.Rcpt(rcpt.Address); err != nil {
+			return fmt.Errorf("SMTP server rejected recipient %s: %w", rcpt.Address, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return client.Quit()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testOAuthCreds are credentials with an OAuth token, which can see non-public photos.
+var testOAuthCreds = &Credentials{APIKey: "key", APISecret: "secret", OAuthToken: "token", OAuthTokenSecret: "token-secret"}
+
+func TestSendDigestRefusesToEmailPrivatePhotos(t *testing.T) {
+	newFakeUserFeed(t, fakePhoto("1", true), fakePhoto("2", false))
+	state := filepath.Join(t.TempDir(), "digest.json")
+
+	_, err := SendDigest(testOAuthCreds, DigestConfig{
+		Feed:     FeedConfig{Users: []string{"alice"}, Count: 10},
+		State:    state,
+		From:     "flickr-rss@example.com",
+		To:       []string{"alice@example.com"},
+		SMTPHost: "127.0.0.1",
+		SMTPPort: 1,
+	})
+	if !errors.Is(err, ErrUsage) || !strings.Contains(err.Error(), "--allow-private") {
+		t.Errorf("got error %v (class %q), want a usage error suggesting --allow-private", err, ErrorClass(err))
+	}
+	if _, err := os.Stat(state); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("state file was written for a digest that wasn't sent (stat error %v)", err)
+	}
+}
+
+func TestSendDigestPrivatePermissions(t *testing.T) {
+	tests := []struct {
+		name     string
+		photos   []map[string]any
+		wantPerm os.FileMode
+	}{
+		{"public", []map[string]any{fakePhoto("1", true)}, 0644},
+		{"non-public", []map[string]any{fakePhoto("1", true), fakePhoto("2", false)}, 0600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFakeUserFeed(t, tt.photos...)
+			dir := t.TempDir()
+			cfg := DigestConfig{
+				Feed:  FeedConfig{Users: []string{"alice"}, Count: 10},
+				State: filepath.Join(dir, "digest.json"),
+				From:  "flickr-rss@example.com",
+				To:    []string{"alice@example.com"},
+				EML:   filepath.Join(dir, "digest.eml"),
+			}
+
+			n, err := SendDigest(testOAuthCreds, cfg)
+			if err != nil {
+				t.Fatalf("SendDigest: %v", err)
+			}
+			if n != len(tt.photos) {
+				t.Errorf("digest has %d photos, want %d", n, len(tt.photos))
+			}
+			for _, path := range []string{cfg.EML, cfg.State} {
+				info, err := os.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if perm := info.Mode().Perm(); perm != tt.wantPerm {
+					t.Errorf("%s has permissions %o, want %o", filepath.Base(path), perm, tt.wantPerm)
+				}
+			}
+		})
+	}
+}
+
+func TestSendDigestRefusesWorldReadablePrivateFiles(t *testing.T) {
+	for _, name := range []string{"digest.eml", "digest.json"} {
+		t.Run(name, func(t *testing.T) {
+			newFakeUserFeed(t, fakePhoto("1", false))
+			dir := t.TempDir()
+			cfg := DigestConfig{
+				Feed:  FeedConfig{Users: []string{"alice"}, Count: 10},
+				State: filepath.Join(dir, "digest.json"),
+				From:  "flickr-rss@example.com",
+				To:    []string{"alice@example.com"},
+				EML:   filepath.Join(dir, "digest.eml"),
+			}
+			existing := filepath.Join(dir, name)
+			if err := os.WriteFile(existing, []byte("{}"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(existing, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := SendDigest(testOAuthCreds, cfg)
+			if !errors.Is(err, ErrUsage) || !strings.Contains(err.Error(), "world-readable") {
+				t.Errorf("got error %v (class %q), want a usage error refusing the world-readable file", err, ErrorClass(err))
+			}
+			if data, err := os.ReadFile(existing); err != nil || string(data) != "{}" {
+				t.Errorf("%s was overwritten with %q (error %v)", name, data, err)
+			}
+		})
+	}
+}
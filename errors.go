@@ -16,29 +16,30 @@ var (
 	ErrUsage        = errors.New("usage error")
 )
 
-// Wrap functions for creating errors with context
+// Wrap functions for creating errors with context. The wrapped error stays in the
+// chain, so errors.Is matches its own class as well as the new one.
 func WrapFlickrAuth(err error, msg string) error {
-	return fmt.Errorf("%s: %w", msg, fmt.Errorf("%w: %s", ErrFlickrAuth, err.Error()))
+	return fmt.Errorf("%s: %w: %w", msg, ErrFlickrAuth, err)
 }
 
 func WrapFlickrServer(err error, msg string) error {
-	return fmt.Errorf("%s: %w", msg, fmt.Errorf("%w: %s", ErrFlickrServer, err.Error()))
+	return fmt.Errorf("%s: %w: %w", msg, ErrFlickrServer, err)
 }
 
 func WrapFlickrUsage(err error, msg string) error {
-	return fmt.Errorf("%s: %w", msg, fmt.Errorf("%w: %s", ErrFlickrUsage, err.Error()))
+	return fmt.Errorf("%s: %w: %w", msg, ErrFlickrUsage, err)
 }
 
 func WrapFlickrAPI(err error, msg string) error {
-	return fmt.Errorf("%s: %w", msg, fmt.Errorf("%w: %s", ErrFlickrAPI, err.Error()))
+	return fmt.Errorf("%s: %w: %w", msg, ErrFlickrAPI, err)
 }
 
 func WrapFileIO(err error, msg string) error {
-	return fmt.Errorf("%s: %w", msg, fmt.Errorf("%w: %s", ErrFileIO, err.Error()))
+	return fmt.Errorf("%s: %w: %w", msg, ErrFileIO, err)
 }
 
 func WrapInputs(err error, msg string) error {
-	return fmt.Errorf("%s: %w", msg, fmt.Errorf("%w: %s", ErrInputs, err.Error()))
+	return fmt.Errorf("%s: %w: %w", msg, ErrInputs, err)
 }
 
 func WrapUsage(msg string) error {
@@ -80,6 +81,24 @@ func NewUsage(msg string) error {
 	return fmt.Errorf("%w: %s", ErrUsage, msg)
 }
 
+// Flickr API error codes for requests that fail authentication or signing: invalid or
+// missing signature, failed login or invalid auth token, insufficient permissions, and
+// invalid API key. Flickr reports these with HTTP status 200.
+const (
+	flickrCodeFirstAuth = 96
+	flickrCodeLastAuth  = 100
+)
+
+// flickrCodeLastMethod is the last Flickr API error code reserved for errors specific
+// to a method, like "User not found" (1) or "Unknown user" (2). These mean the request
+// named something that doesn't exist or passed an invalid argument, so retrying won't help.
+const flickrCodeLastMethod = 95
+
+// flickrRequestErrorCodes are Flickr API error codes for requests flickr-rss built
+// incorrectly: format not found, method not found, invalid SOAP or XML-RPC envelope,
+// and bad URL found.
+var flickrRequestErrorCodes = map[int]bool{111: true, 112: true, 114: true, 115: true, 116: true}
+
 // ClassifyFlickrError classifies a Flickr API error by HTTP status code or error code
 func ClassifyFlickrError(statusCode int, errorCode int, message string) error {
 	switch statusCode {
@@ -90,6 +109,15 @@ func ClassifyFlickrError(statusCode int, errorCode int, message string) error {
 	case 500, 501, 502, 503, 504, 505:
 		return NewFlickrServer(message)
 	default:
+		if errorCode >= flickrCodeFirstAuth && errorCode <= flickrCodeLastAuth {
+			return NewFlickrAuth(message)
+		}
+		if errorCode >= 1 && errorCode <= flickrCodeLastMethod {
+			return NewInputs(message)
+		}
+		if flickrRequestErrorCodes[errorCode] {
+			return NewFlickrUsage(message)
+		}
 		if errorCode >= 400 && errorCode < 500 {
 			if errorCode == 401 || errorCode == 403 {
 				return NewFlickrAuth(message)
@@ -101,3 +129,28 @@ func ClassifyFlickrError(statusCode int, errorCode int, message string) error {
 		return NewFlickrServer(message)
 	}
 }
+
+// ErrorClass returns a short, stable name for the category of err, for use in logs
+// and metrics.
+func ErrorClass(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrFlickrAuth):
+		return "flickr_auth"
+	case errors.Is(err, ErrFlickrServer):
+		return "flickr_server"
+	case errors.Is(err, ErrFlickrUsage):
+		return "flickr_usage"
+	case errors.Is(err, ErrInputs):
+		return "inputs"
+	case errors.Is(err, ErrFlickrAPI):
+		return "flickr_api"
+	case errors.Is(err, ErrFileIO):
+		return "file_io"
+	case errors.Is(err, ErrUsage):
+		return "usage"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	ec "github.com/cdzombak/exitcode_go"
+)
+
+func TestExitCodeForError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"invalid API key", ClassifyFlickrError(200, 100, "Invalid API Key (Key has invalid format)"), ec.NoPermission},
+		{"invalid signature", ClassifyFlickrError(200, 96, "Invalid signature"), ec.NoPermission},
+		{"missing signature", ClassifyFlickrError(200, 97, "Missing signature"), ec.NoPermission},
+		{"invalid auth token", ClassifyFlickrError(200, 98, "Invalid auth token"), ec.NoPermission},
+		{"insufficient permissions", ClassifyFlickrError(200, 99, "Insufficient permissions"), ec.NoPermission},
+		{"user not found", ClassifyFlickrError(200, 1, "User not found"), ec.NotConfigured},
+		{"unknown user", ClassifyFlickrError(200, 2, "Unknown user"), ec.NotConfigured},
+		{"wrapped user not found", WrapFlickrAPI(ClassifyFlickrError(200, 1, "User not found"), "failed to look up user"), ec.NotConfigured},
+		{"method not found", ClassifyFlickrError(200, 112, "Method \"flickr.foo\" not found"), ec.Usage},
+		{"write failed", ClassifyFlickrError(200, 106, "Write operation failed"), ec.Unavailable},
+		{"service unavailable", ClassifyFlickrError(200, 105, "Service currently unavailable"), ec.Unavailable},
+		{"HTTP unauthorized", ClassifyFlickrError(401, 0, "API request failed with status 401"), ec.NoPermission},
+		{"HTTP bad request", ClassifyFlickrError(400, 0, "API request failed with status 400"), ec.Usage},
+		{"HTTP server error", ClassifyFlickrError(503, 0, "API request failed with status 503"), ec.Unavailable},
+		{"doctor check failure", fmt.Errorf("1 of 5 checks failed: %w", ClassifyFlickrError(200, 100, "Invalid API Key")), ec.NoPermission},
+		{"wrapped auth error", WrapFlickrAPI(ClassifyFlickrError(200, 98, "Invalid auth token"), "failed to fetch photos"), ec.NoPermission},
+		{"wrapped server error", WrapFlickrAPI(ClassifyFlickrError(502, 0, "API request failed with status 502"), "failed to look up user"), ec.Unavailable},
+		{"request failure", WrapFlickrAPI(errors.New("connection refused"), "failed to make API request"), ec.Failure},
+		{"file error", WrapFileIO(errors.New("permission denied"), "failed to write feed"), ec.IOErr},
+		{"invalid credentials", NewInputs("API key is required"), ec.NotConfigured},
+		{"usage", NewUsage("photo count must be greater than zero"), ec.InvalidArgument},
+		{"other", errors.New("something else"), ec.Failure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCodeForError(tt.err); got != tt.want {
+				t.Errorf("exitCodeForError(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapPreservesInnerError(t *testing.T) {
+	inner := ClassifyFlickrError(200, 98, "Invalid auth token")
+	err := WrapFlickrAPI(inner, "failed to fetch photos")
+
+	if !errors.Is(err, inner) || !errors.Is(err, ErrFlickrAuth) || !errors.Is(err, ErrFlickrAPI) {
+		t.Errorf("%v should wrap %v and match both ErrFlickrAuth and ErrFlickrAPI", err, inner)
+	}
+	if got := ErrorClass(err); got != "flickr_auth" {
+		t.Errorf("ErrorClass(%v) = %q, want flickr_auth", err, got)
+	}
+	if got, want := err.Error(), "failed to fetch photos: flickr api error: flickr authentication error: Invalid auth token"; got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+}
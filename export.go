@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// exportExtras requests every photo detail Flickr's photo list methods can return.
+const exportExtras = "description,license,date_upload,date_taken,owner_name,icon_server,original_format," +
+	"last_update,geo,tags,machine_tags,o_dims,views,media,media_status,path_alias," +
+	"url_sq,url_t,url_s,url_q,url_m,url_n,url_z,url_c,url_l,url_o"
+
+const (
+	// originalsConcurrency bounds the number of originals downloaded at once.
+	originalsConcurrency = 4
+	// originalTimeout bounds how long downloading each original may take.
+	originalTimeout = 10 * time.Minute
+)
+
+// ExportConfig describes an export: the photos to export, and where to write them.
+type ExportConfig struct {
+	// Users lists users whose entire photostreams are exported.
+	Users []string
+	// FriendsFamily exports the entire photostream of each of the authenticated user's
+	// contacts matching FFContacts.
+	FriendsFamily bool
+	FFContacts    string
+	// Albums lists albums to export, as album IDs or URLs.
+	Albums []string
+
+	// Manifest is the JSON Lines file each exported photo is appended to.
+	Manifest string
+	// Originals is a directory to download each photo's original file to.
+	Originals string
+}
+
+// ExportRecord is a line of an export manifest.
+type ExportRecord struct {
+	// Source identifies the photostream or album the photo was exported from.
+	Source     string    `json:"source"`
+	ExportedAt time.Time `json:"exported_at"`
+	// OriginalFile is the path of the downloaded original, if any.
+	OriginalFile string `json:"original_file,omitempty"`
+	// Photo is the photo's complete metadata, as returned by Flickr.
+	Photo json.RawMessage `json:"photo"`
+}
+
+// ExportResult summarizes an export.
+type ExportResult struct {
+	// Exported is the number of photos added to the manifest.
+	Exported int
+	// Skipped is the number of photos already in the manifest.
+	Skipped int
+}
+
+// exportCheckpoint records an export's progress, so an interrupted export can resume.
+type exportCheckpoint struct {
+	// Sources identifies the export's sources; the checkpoint only applies to an
+	// export of the same sources.
+	Sources []string `json:"sources"`
+	// Source and Page are the index of the source and the page to resume from.
+	Source int `json:"source"`
+	Page   int `json:"page"`
+}
+
+// exportSource is a paged photo list to export.
+type exportSource struct {
+	key     string
+	method  string
+	params  map[string]string
+	listKey string
+}
+
+// exportPhoto holds the fields of a photo's metadata the export itself needs.
+type exportPhoto struct {
+	ID             string     `json:"id"`
+	URLOriginal    string     `json:"url_o"`
+	OriginalSecret string     `json:"originalsecret"`
+	OriginalFormat string     `json:"originalformat"`
+	IsPublic       flexString `json:"ispublic"`
+}
+
+var albumURLPattern = regexp.MustCompile(`^https?://(?:www\.)?flickr\.com/photos/[^/]+/(?:albums|sets)/([0-9]+)`)
+
+// Export pages through each source in full, appending every photo not already in the
+// manifest to it, and optionally downloading each photo's original. Progress is saved
+// to a checkpoint file next to the manifest as each page and source starts, so an
+// interrupted export resumes where it left off; the checkpoint is removed once the
+// export completes.
+func Export(creds *Credentials, cfg ExportConfig) (ExportResult, error) {
+	var result ExportResult
+
+	if len(cfg.Users) == 0 && !cfg.FriendsFamily && len(cfg.Albums) == 0 {
+		return result, NewUsage("nothing to export: give one or more users, --ff, or --album")
+	}
+	if cfg.FriendsFamily && !creds.HasOAuth() {
+		return result, NewUsage("exporting friends & family requires OAuth authentication. Run 'flickr-rss auth' first")
+	}
+
+	client := NewFlickrClient(creds)
+	sources, err := exportSources(client, cfg)
+	if err != nil {
+		return result, err
+	}
+	keys := make([]string, len(sources))
+	for i, source := range sources {
+		keys[i] = source.key
+	}
+
+	exported, err := readManifestIDs(cfg.Manifest)
+	if err != nil {
+		return result, err
+	}
+
+	checkpointPath := cfg.Manifest + ".checkpoint"
+	checkpoint := exportCheckpoint{Sources: keys, Page: 1}
+	if previous, err := loadExportCheckpoint(checkpointPath); err != nil {
+		return result, err
+	} else if previous != nil && slices.Equal(previous.Sources, keys) {
+		slog.Info("Resuming export", "source", keys[previous.Source], "page", previous.Page)
+		checkpoint = *previous
+	}
+
+	// Metadata and originals of non-public photos are written readable only by the
+	// current user
+	perm := os.FileMode(0644)
+	if creds.HasOAuth() {
+		perm = 0600
+	}
+	manifest, err := os.OpenFile(cfg.Manifest, os.O_WRONLY|os.O_APPEND|os.O_CREATE, perm)
+	if err != nil {
+		return result, WrapFileIO(err, fmt.Sprintf("failed to open manifest %s", cfg.Manifest))
+	}
+	defer manifest.Close()
+
+	if cfg.Originals != "" {
+		if err := os.MkdirAll(cfg.Originals, 0755); err != nil {
+			return result, WrapFileIO(err, fmt.Sprintf("failed to create originals directory %s", cfg.Originals))
+		}
+	}
+	httpClient := &http.Client{Timeout: originalTimeout}
+
+	failedOriginals := 0
+	for ; checkpoint.Source < len(sources); checkpoint.Source, checkpoint.Page = checkpoint.Source+1, 1 {
+		// Record moving on to this source, so a failure in it doesn't resume at the last
+		// page of the previous one
+		if err := saveExportCheckpoint(checkpointPath, checkpoint); err != nil {
+			return result, err
+		}
+
+		source := sources[checkpoint.Source]
+		params := maps.Clone(source.params)
+		params["extras"] = exportExtras
+		err := client.forEachPhotoPage(source.method, params, source.listKey, maxPerPage, checkpoint.Page, func(page *RawPhotoPage) (bool, error) {
+			var records []ExportRecord
+			var photos []exportPhoto
+			for _, raw := range page.Photos {
+				var photo exportPhoto
+				if err := json.Unmarshal(raw, &photo); err != nil || photo.ID == "" {
+					slog.Warn("Skipping photo with unparseable metadata", "source", source.key, "error", err)
+					continue
+				}
+				if exported[photo.ID] {
+					result.Skipped++
+					continue
+				}
+				exported[photo.ID] = true
+				records = append(records, ExportRecord{Source: source.key, Photo: raw})
+				photos = append(photos, photo)
+			}
+
+			failed := make([]bool, len(records))
+			if cfg.Originals != "" {
+				forEachConcurrently(len(records), originalsConcurrency, func(i int) {
+					file, err := downloadOriginal(httpClient, photos[i], cfg.Originals)
+					if err != nil {
+						slog.Warn("Failed to download original", "photo_id", photos[i].ID, "error", err)
+						failed[i] = true
+						return
+					}
+					records[i].OriginalFile = file
+				})
+			}
+
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			for i := range records {
+				if failed[i] {
+					// Leave it out of the manifest, so the next export retries it
+					failedOriginals++
+					delete(exported, photos[i].ID)
+					continue
+				}
+				records[i].ExportedAt = time.Now().UTC()
+				if err := enc.Encode(records[i]); err != nil {
+					return false, fmt.Errorf("failed to encode manifest record: %w", err)
+				}
+				result.Exported++
+			}
+			if _, err := manifest.Write(buf.Bytes()); err != nil {
+				return false, WrapFileIO(err, fmt.Sprintf("failed to write manifest %s", cfg.Manifest))
+			}
+			if err := manifest.Sync(); err != nil {
+				return false, WrapFileIO(err, fmt.Sprintf("failed to sync manifest %s", cfg.Manifest))
+			}
+
+			slog.Info("Exported page", "source", source.key, "page", checkpoint.Page, "pages", page.Pages,
+				"exported", result.Exported, "skipped", result.Skipped)
+
+			if page.Last() {
+				return false, nil
+			}
+			checkpoint.Page++
+			return true, saveExportCheckpoint(checkpointPath, checkpoint)
+		})
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if err := os.Remove(checkpointPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return result, WrapFileIO(err, fmt.Sprintf("failed to remove checkpoint %s", checkpointPath))
+	}
+
+	if failedOriginals > 0 {
+		return result, NewFlickrAPI(fmt.Sprintf("%d originals failed to download and were left out of the manifest; run the export again to retry them", failedOriginals))
+	}
+	return result, nil
+}
+
+// exportSources resolves an export's users, contacts, and albums to photo lists.
+func exportSources(client *FlickrClient, cfg ExportConfig) ([]exportSource, error) {
+	var sources []exportSource
+	seen := make(map[string]bool)
+	add := func(source exportSource) {
+		if !seen[source.key] {
+			seen[source.key] = true
+			sources = append(sources, source)
+		}
+	}
+
+	userSource := func(userID string) exportSource {
+		method, params := client.userPhotosMethod(userID, 0)
+		return exportSource{
+			key:     "user:" + userID,
+			method:  method,
+			params:  params,
+			listKey: "photos",
+		}
+	}
+
+	for _, user := range cfg.Users {
+		userID, _, err := resolveUser(client, user)
+		if err != nil {
+			return nil, err
+		}
+		add(userSource(userID))
+	}
+
+	if cfg.FriendsFamily {
+		if !slices.Contains([]string{"ff", "friends", "family", "all"}, cfg.FFContacts) {
+			return nil, NewUsage(fmt.Sprintf("invalid friends & family contacts '%s' (must be one of: ff, friends, family, all)", cfg.FFContacts))
+		}
+		contacts, err := client.GetContacts()
+		if err != nil {
+			return nil, WrapFlickrAPI(err, "failed to list contacts")
+		}
+		for _, userID := range filterContacts(contacts, cfg.FFContacts) {
+			add(userSource(userID))
+		}
+	}
+
+	for _, album := range cfg.Albums {
+		albumID, err := parseAlbumID(album)
+		if err != nil {
+			return nil, err
+		}
+		add(exportSource{
+			key:     "album:" + albumID,
+			method:  "flickr.photosets.getPhotos",
+			params:  map[string]string{"photoset_id": albumID},
+			listKey: "photoset",
+		})
+	}
+
+	return sources, nil
+}
+
+// parseAlbumID returns the ID of an album given as an ID or an album URL.
+func parseAlbumID(album string) (string, error) {
+	if !containsNonNumeric(album) && album != "" {
+		return album, nil
+	}
+	if m := albumURLPattern.FindStringSubmatch(album); m != nil {
+		return m[1], nil
+	}
+	return "", NewUsage(fmt.Sprintf("invalid album '%s': give an album ID or URL", album))
+}
+
+// downloadOriginal downloads a photo's original file into dir, returning its path. A
+// photo whose owner doesn't allow its original to be downloaded has no original URL;
+// that's logged, and isn't an error.
+func downloadOriginal(client *http.Client, photo exportPhoto, dir string) (string, error) {
+	if photo.URLOriginal == "" {
+		slog.Info("Original isn't available; its owner may not allow downloads", "photo_id", photo.ID)
+		return "", nil
+	}
+
+	name := fmt.Sprintf("%s_%s_o.%s", photo.ID, photo.OriginalSecret, photo.OriginalFormat)
+	if photo.OriginalSecret == "" || photo.OriginalFormat == "" {
+		u, err := url.Parse(photo.URLOriginal)
+		if err != nil {
+			return "", err
+		}
+		name = path.Base(u.Path)
+	}
+	file := filepath.Join(dir, name)
+
+	perm := os.FileMode(0644)
+	if photo.IsPublic != "1" {
+		perm = 0600
+	}
+	if err := downloadFile(client, photo.URLOriginal, file, perm); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+// readManifestIDs returns the IDs of the photos in an export manifest. If the manifest
+// ends with a partial line, left by an export that was interrupted while writing it,
+// the partial line is removed.
+func readManifestIDs(manifest string) (map[string]bool, error) {
+	ids := make(map[string]bool)
+
+	data, err := os.ReadFile(manifest)
+	if errors.Is(err, os.ErrNotExist) {
+		return ids, nil
+	}
+	if err != nil {
+		return nil, WrapFileIO(err, fmt.Sprintf("failed to read manifest %s", manifest))
+	}
+
+	if complete := bytes.LastIndexByte(data, '\n') + 1; complete < len(data) {
+		slog.Warn("Removing partial record from the end of the manifest", "path", manifest)
+		if err := os.Truncate(manifest, int64(complete)); err != nil {
+			return nil, WrapFileIO(err, fmt.Sprintf("failed to repair manifest %s", manifest))
+		}
+		data = data[:complete]
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		var record struct {
+			Photo exportPhoto `json:"photo"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, WrapInputs(err, fmt.Sprintf("failed to parse manifest %s, line %d", manifest, line))
+		}
+		ids[record.Photo.ID] = true
+	}
+	return ids, nil
+}
+
+// loadExportCheckpoint reads an export checkpoint, returning nil if there is none.
+func loadExportCheckpoint(path string) (*exportCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, WrapFileIO(err, fmt.Sprintf("failed to read checkpoint %s", path))
+	}
+	var checkpoint exportCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, WrapInputs(err, fmt.Sprintf("failed to parse checkpoint %s", path))
+	}
+	if checkpoint.Source < 0 || checkpoint.Source >= len(checkpoint.Sources) || checkpoint.Page < 1 {
+		return nil, NewInputs(fmt.Sprintf("invalid checkpoint %s; remove it to start the export over", path))
+	}
+	return &checkpoint, nil
+}
+
+func saveExportCheckpoint(path string, checkpoint exportCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	return writeFileAtomic(path, 0644, func(w io.Writer) error {
+		if _, err := w.Write(append(data, '\n')); err != nil {
+			return WrapFileIO(err, fmt.Sprintf("failed to write checkpoint %s", path))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestReadManifestIDsRepairsPartialRecord(t *testing.T) {
+	manifest := filepath.Join(t.TempDir(), "photos.jsonl")
+	const complete = `{"source":"album:1","photo":{"id":"1"}}` + "\n" + `{"source":"album:1","photo":{"id":"2"}}` + "\n"
+	if err := os.WriteFile(manifest, []byte(complete+`{"source":"album:1","pho`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := readManifestIDs(manifest)
+	if err != nil {
+		t.Fatalf("readManifestIDs: %v", err)
+	}
+	if want := map[string]bool{"1": true, "2": true}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("readManifestIDs = %v, want %v", ids, want)
+	}
+	if data, err := os.ReadFile(manifest); err != nil || string(data) != complete {
+		t.Errorf("repaired manifest is %q (error %v), want %q", data, err, complete)
+	}
+}
+
+func TestLoadExportCheckpoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    *exportCheckpoint
+		wantErr bool
+	}{
+		{"missing", "", nil, false},
+		{"valid", `{"sources":["user:1","album:2"],"source":1,"page":3}`, &exportCheckpoint{Sources: []string{"user:1", "album:2"}, Source: 1, Page: 3}, false},
+		{"source out of range", `{"sources":["user:1"],"source":1,"page":1}`, nil, true},
+		{"no page", `{"sources":["user:1"],"source":0,"page":0}`, nil, true},
+		{"unparseable", `{"sources":`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "photos.jsonl.checkpoint")
+			if tt.data != "" {
+				if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := loadExportCheckpoint(path)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInputs) {
+					t.Errorf("got error %v, want an inputs error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadExportCheckpoint: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadExportCheckpoint = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportResumesAtFailedSource(t *testing.T) {
+	// Album 1 has two pages; album 2 fails until unavailable is cleared
+	albums := map[string][][]map[string]any{
+		"1": {{fakePhoto("11", true), fakePhoto("12", true)}, {fakePhoto("13", true)}},
+		"2": {{fakePhoto("21", true)}},
+	}
+	unavailable := true
+	fake := newFakeFlickr(t, map[string]func(url.Values) any{
+		"flickr.photosets.getPhotos": func(params url.Values) any {
+			id := params.Get("photoset_id")
+			if id == "2" && unavailable {
+				return map[string]any{"stat": "fail", "code": 105, "message": "Service currently unavailable"}
+			}
+			page, _ := strconv.Atoi(params.Get("page"))
+			return map[string]any{"photoset": map[string]any{
+				"id": id, "page": page, "pages": len(albums[id]), "photo": albums[id][page-1],
+			}}
+		},
+	})
+
+	creds := &Credentials{APIKey: "key", APISecret: "secret"}
+	manifest := filepath.Join(t.TempDir(), "photos.jsonl")
+	cfg := ExportConfig{Albums: []string{"1", "2"}, Manifest: manifest}
+
+	if _, err := Export(creds, cfg); !errors.Is(err, ErrFlickrServer) {
+		t.Fatalf("got error %v, want a Flickr server error", err)
+	}
+	checkpoint, err := loadExportCheckpoint(manifest + ".checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &exportCheckpoint{Sources: []string{"album:1", "album:2"}, Source: 1, Page: 1}
+	if !reflect.DeepEqual(checkpoint, want) {
+		t.Fatalf("checkpoint after failing in album 2 = %+v, want %+v", checkpoint, want)
+	}
+
+	unavailable = false
+	result, err := Export(creds, cfg)
+	if err != nil {
+		t.Fatalf("resumed Export: %v", err)
+	}
+	if result != (ExportResult{Exported: 1}) {
+		t.Errorf("resumed Export = %+v, want only album 2's photo exported", result)
+	}
+	for _, req := range fake.Requests("flickr.photosets.getPhotos")[3:] {
+		if id := req.Params.Get("photoset_id"); id != "2" {
+			t.Errorf("resumed export fetched album %s again", id)
+		}
+	}
+	if _, err := os.Stat(manifest + ".checkpoint"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("checkpoint remains after the export completed (stat error %v)", err)
+	}
+	if got, want := manifestPhotoIDs(t, manifest), []string{"11", "12", "13", "21"}; !slices.Equal(got, want) {
+		t.Errorf("manifest has photos %v, want %v", got, want)
+	}
+}
+
+// manifestPhotoIDs returns the IDs of the photos in an export manifest, in order.
+func manifestPhotoIDs(t *testing.T, manifest string) []string {
+	t.Helper()
+	f, err := os.Open(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var ids []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record struct {
+			Photo exportPhoto `json:"photo"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("parsing manifest line %q: %v", scanner.Text(), err)
+		}
+		ids = append(ids, record.Photo.ID)
+	}
+	return ids
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// exifConcurrency bounds the number of concurrent flickr.photos.getExif requests.
+const exifConcurrency = 4
+
+// ItemExtras selects optional per-photo data to include in feed items.
+type ItemExtras struct {
+	Tags    bool
+	Geo     bool
+	EXIF    bool
+	License bool
+	Views   bool
+}
+
+// ParseItemExtras parses a list of extra names, as given to the --include flag.
+func ParseItemExtras(names []string) (ItemExtras, error) {
+	var extras ItemExtras
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "tags":
+			extras.Tags = true
+		case "geo":
+			extras.Geo = true
+		case "exif":
+			extras.EXIF = true
+		case "license":
+			extras.License = true
+		case "views":
+			extras.Views = true
+		case "":
+		default:
+			return ItemExtras{}, NewUsage(fmt.Sprintf("invalid include option '%s' (must be one of: tags, geo, exif, license, views)", name))
+		}
+	}
+	return extras, nil
+}
+
+// APIExtras returns the Flickr photo list extras needed for these options.
+// EXIF isn't available as a list extra; see fetchPhotoEXIF.
+func (e ItemExtras) APIExtras() []string {
+	var extras []string
+	if e.Tags {
+		extras = append(extras, "tags")
+	}
+	if e.Geo {
+		extras = append(extras, "geo")
+	}
+	if e.License {
+		extras = append(extras, "license")
+	}
+	if e.Views {
+		extras = append(extras, "views")
+	}
+	return extras
+}
+
+// PhotoEXIF summarizes the camera settings recorded in a photo's EXIF data.
+type PhotoEXIF struct {
+	Camera       string
+	Lens         string
+	FocalLength  string
+	Aperture     string
+	ShutterSpeed string
+	ISO          string
+}
+
+func (e *PhotoEXIF) IsEmpty() bool {
+	return e == nil || (e.Camera == "" && e.Lens == "" && e.FocalLength == "" &&
+		e.Aperture == "" && e.ShutterSpeed == "" && e.ISO == "")
+}
+
+// fetchPhotoEXIF populates the EXIF field of each photo, making up to
+// exifConcurrency requests at a time. Photos whose EXIF data can't be
+// fetched (for example, because the owner has hidden it) are left without it.
+func fetchPhotoEXIF(client *FlickrClient, photos []FlickrPhoto) {
+	forEachConcurrently(len(photos), exifConcurrency, func(i int) {
+		exif, err := client.GetPhotoEXIF(photos[i].ID, photos[i].Secret)
+		if err != nil {
+			client.logger.Debug("No EXIF for photo", "photo_id", photos[i].ID, "error", err)
+			return
+		}
+		photos[i].EXIF = exif
+	})
+}
+
+// forEachConcurrently calls fn for each index in [0, n), running at most
+// limit calls at once, and returns when all calls have finished.
+func forEachConcurrently(n, limit int, fn func(i int)) {
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// licenseInfo describes a Flickr license.
+// See https://www.flickr.com/services/api/flickr.photos.licenses.getInfo.html
+type licenseInfo struct {
+	Name string
+	URL  string
+}
+
+var flickrLicenses = map[string]licenseInfo{
+	"0":  {"All Rights Reserved", ""},
+	"1":  {"CC BY-NC-SA 2.0", "https://creativecommons.org/licenses/by-nc-sa/2.0/"},
+	"2":  {"CC BY-NC 2.0", "https://creativecommons.org/licenses/by-nc/2.0/"},
+	"3":  {"CC BY-NC-ND 2.0", "https://creativecommons.org/licenses/by-nc-nd/2.0/"},
+	"4":  {"CC BY 2.0", "https://creativecommons.org/licenses/by/2.0/"},
+	"5":  {"CC BY-SA 2.0", "https://creativecommons.org/licenses/by-sa/2.0/"},
+	"6":  {"CC BY-ND 2.0", "https://creativecommons.org/licenses/by-nd/2.0/"},
+	"7":  {"No known copyright restrictions", "https://www.flickr.com/commons/usage/"},
+	"8":  {"United States Government Work", "http://www.usa.gov/copyright.shtml"},
+	"9":  {"Public Domain Dedication (CC0)", "https://creativecommons.org/publicdomain/zero/1.0/"},
+	"10": {"Public Domain Mark", "https://creativecommons.org/publicdomain/mark/1.0/"},
+}
+
+func licenseFor(id string) licenseInfo {
+	if info, ok := flickrLicenses[id]; ok {
+		return info
+	}
+	return licenseInfo{Name: fmt.Sprintf("License %s", id)}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v2"
+)
+
+// FilterRules select which fetched photos appear in a feed. Empty rules match everything.
+type FilterRules struct {
+	// IncludeTags keeps only photos with at least one of these tags.
+	IncludeTags []string `yaml:"include_tags"`
+	// ExcludeTags drops photos with any of these tags.
+	ExcludeTags []string `yaml:"exclude_tags"`
+	// TitleMatch keeps only photos whose titles match this regular expression.
+	TitleMatch string `yaml:"title_match"`
+	// TitleExclude drops photos whose titles match this regular expression.
+	TitleExclude string `yaml:"title_exclude"`
+	// Licenses keeps only photos with one of these Flickr license IDs.
+	Licenses []string `yaml:"licenses"`
+	// Media keeps only photos ("photo") or only videos ("video").
+	Media string `yaml:"media"`
+	// MinWidth and MinHeight drop photos smaller than these dimensions, in pixels.
+	MinWidth  int `yaml:"min_width"`
+	MinHeight int `yaml:"min_height"`
+	// Since and Until keep only photos dated within this range, per the feed's date source.
+	// Dates are given as YYYY-MM-DD or RFC 3339 timestamps.
+	Since string `yaml:"since"`
+	Until string `yaml:"until"`
+	// Owners keeps only photos from these users, given as NSIDs or usernames.
+	Owners []string `yaml:"owners"`
+	// ExcludeOwners drops photos from these users, given as NSIDs or usernames.
+	ExcludeOwners []string `yaml:"exclude_owners"`
+	// Privacy keeps only photos with one of these privacy levels: public, friends, family,
+	// or private. A photo shared with both friends and family matches either.
+	Privacy []string `yaml:"privacy"`
+}
+
+// LoadFilterRules reads filter rules from a YAML file.
+func LoadFilterRules(filename string) (FilterRules, error) {
+	var rules FilterRules
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return rules, WrapFileIO(err, fmt.Sprintf("failed to read filters file %s", filename))
+	}
+	if err := yaml.UnmarshalStrict(data, &rules); err != nil {
+		return rules, WrapInputs(err, fmt.Sprintf("failed to parse filters file %s", filename))
+	}
+	return rules, nil
+}
+
+// Merge returns r combined with other: list rules are combined, and other's
+// non-empty single-value rules take precedence.
+func (r FilterRules) Merge(other FilterRules) FilterRules {
+	merged := r
+	merged.IncludeTags = append(append([]string(nil), r.IncludeTags...), other.IncludeTags...)
+	merged.ExcludeTags = append(append([]string(nil), r.ExcludeTags...), other.ExcludeTags...)
+	merged.Licenses = append(append([]string(nil), r.Licenses...), other.Licenses...)
+	merged.Owners = append(append([]string(nil), r.Owners...), other.Owners...)
+	merged.ExcludeOwners = append(append([]string(nil), r.ExcludeOwners...), other.ExcludeOwners...)
+	merged.Privacy = append(append([]string(nil), r.Privacy...), other.Privacy...)
+	if other.TitleMatch != "" {
+		merged.TitleMatch = other.TitleMatch
+	}
+	if other.TitleExclude != "" {
+		merged.TitleExclude = other.TitleExclude
+	}
+	if other.Media != "" {
+		merged.Media = other.Media
+	}
+	if other.MinWidth != 0 {
+		merged.MinWidth = other.MinWidth
+	}
+	if other.MinHeight != 0 {
+		merged.MinHeight = other.MinHeight
+	}
+	if other.Since != "" {
+		merged.Since = other.Since
+	}
+	if other.Until != "" {
+		merged.Until = other.Until
+	}
+	return merged
+}
+
+// APIExtras returns the Flickr photo list extras needed to evaluate these rules.
+func (r FilterRules) APIExtras() []string {
+	var extras []string
+	if len(r.IncludeTags) > 0 || len(r.ExcludeTags) > 0 {
+		extras = append(extras, "tags")
+	}
+	if len(r.Licenses) > 0 {
+		extras = append(extras, "license")
+	}
+	if r.MinWidth > 0 || r.MinHeight > 0 {
+		extras = append(extras, "o_dims")
+	}
+	return extras
+}
+
+// AllowsNonPublic reports whether these rules allow photos that aren't public.
+func (r FilterRules) AllowsNonPublic() bool {
+	if len(r.Privacy) == 0 {
+		return true
+	}
+	for _, level := range r.Privacy {
+		if normalizeKeyword(level) != "public" {
+			return true
+		}
+	}
+	return false
+}
+
+// APIPrivacyFilter returns the flickr.people.getPhotos privacy_filter value matching
+// these rules, or 0 if the rules can't be expressed as a single privacy_filter value.
+func (r FilterRules) APIPrivacyFilter() int {
+	if len(r.Privacy) != 1 {
+		return 0
+	}
+	switch normalizeKeyword(r.Privacy[0]) {
+	case "public":
+		return 1
+	case "private":
+		return 5
+	default:
+		// The API's friends and family filters exclude photos shared with both
+		return 0
+	}
+}
+
+// PhotoFilter is a compiled, ready-to-apply set of FilterRules.
+type PhotoFilter struct {
+	includeTags   map[string]bool
+	excludeTags   map[string]bool
+	titleMatch    *regexp.Regexp
+	titleExclude  *regexp.Regexp
+	licenses      map[string]bool
+	media         string
+	minWidth      int
+	minHeight     int
+	since         time.Time
+	until         time.Time
+	owners        map[string]bool
+	excludeOwners map[string]bool
+	privacy       map[string]bool
+	dateSource    DateSource
+}
+
+// Compile validates the rules and prepares them for use. Date range rules
+// are evaluated against each photo's date from dateSource.
+func (r FilterRules) Compile(dateSource DateSource) (*PhotoFilter, error) {
+	f := &PhotoFilter{
+		includeTags:   normalizedTagSet(r.IncludeTags),
+		excludeTags:   normalizedTagSet(r.ExcludeTags),
+		licenses:      stringSet(r.Licenses, strings.TrimSpace),
+		owners:        stringSet(r.Owners, normalizeOwner),
+		excludeOwners: stringSet(r.ExcludeOwners, normalizeOwner),
+		privacy:       stringSet(r.Privacy, normalizeKeyword),
+		minWidth:      r.MinWidth,
+		minHeight:     r.MinHeight,
+		dateSource:    dateSource,
+	}
+
+	var err error
+	if r.TitleMatch != "" {
+		if f.titleMatch, err = regexp.Compile(r.TitleMatch); err != nil {
+			return nil, NewUsage(fmt.Sprintf("invalid title match pattern '%s': %s", r.TitleMatch, err))
+		}
+	}
+	if r.TitleExclude != "" {
+		if f.titleExclude, err = regexp.Compile(r.TitleExclude); err != nil {
+			return nil, NewUsage(fmt.Sprintf("invalid title exclude pattern '%s': %s", r.TitleExclude, err))
+		}
+	}
+
+	switch media := strings.ToLower(strings.TrimSpace(r.Media)); media {
+	case "", "all":
+	case "photo", "photos":
+		f.media = "photo"
+	case "video", "videos":
+		f.media = "video"
+	default:
+		return nil, NewUsage(fmt.Sprintf("invalid media type '%s' (must be one of: photo, video)", r.Media))
+	}
+
+	for level := range f.privacy {
+		switch level {
+		case "public", "friends", "family", "private":
+		default:
+			return nil, NewUsage(fmt.Sprintf("invalid privacy level '%s' (must be one of: public, friends, family, private)", level))
+		}
+	}
+
+	if r.Since != "" {
+		if f.since, err = parseFilterDate(r.Since, false); err != nil {
+			return nil, err
+		}
+	}
+	if r.Until != "" {
+		if f.until, err = parseFilterDate(r.Until, true); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// Apply returns the photos matching the filter, preserving their order.
+func (f *PhotoFilter) Apply(photos []FlickrPhoto) []FlickrPhoto {
+	kept := make([]FlickrPhoto, 0, len(photos))
+	for _, photo := range photos {
+		if f.Match(photo) {
+			kept = append(kept, photo)
+		}
+	}
+	return kept
+}
+
+// Match reports whether a single photo passes every rule in the filter.
+func (f *PhotoFilter) Match(photo FlickrPhoto) bool {
+	if len(f.includeTags) > 0 || len(f.excludeTags) > 0 {
+		hasIncluded := false
+		for _, tag := range strings.Fields(photo.Tags) {
+			tag = normalizeTag(tag)
+			if f.excludeTags[tag] {
+				return false
+			}
+			if f.includeTags[tag] {
+				hasIncluded = true
+			}
+		}
+		if len(f.includeTags) > 0 && !hasIncluded {
+			return false
+		}
+	}
+
+	if f.titleMatch != nil && !f.titleMatch.MatchString(photo.Title) {
+		return false
+	}
+	if f.titleExclude != nil && f.titleExclude.MatchString(photo.Title) {
+		return false
+	}
+
+	if len(f.licenses) > 0 && !f.licenses[string(photo.License)] {
+		return false
+	}
+
+	if f.media != "" {
+		media := photo.Media
+		if media == "" {
+			media = "photo"
+		}
+		if media != f.media {
+			return false
+		}
+	}
+
+	if f.minWidth > 0 || f.minHeight > 0 {
+		width, height := photoDimensions(photo)
+		if width < f.minWidth || height < f.minHeight {
+			return false
+		}
+	}
+
+	if !f.since.IsZero() || !f.until.IsZero() {
+		date := photoDate(photo, f.dateSource)
+		if !f.since.IsZero() && date.Before(f.since) {
+			return false
+		}
+		if !f.until.IsZero() && date.After(f.until) {
+			return false
+		}
+	}
+
+	if len(f.owners) > 0 || len(f.excludeOwners) > 0 {
+		owner := normalizeOwner(photo.Owner)
+		username := normalizeOwner(photo.OwnerDisplayName())
+		if f.excludeOwners[owner] || (username != "" && f.excludeOwners[username]) {
+			return false
+		}
+		if len(f.owners) > 0 && !f.owners[owner] && (username == "" || !f.owners[username]) {
+			return false
+		}
+	}
+
+	if len(f.privacy) > 0 && !f.matchPrivacy(photo) {
+		return false
+	}
+
+	return true
+}
+
+func (f *PhotoFilter) matchPrivacy(photo FlickrPhoto) bool {
+	if !photo.IsNonPublic() {
+		return f.privacy["public"]
+	}
+	isFriend := photo.IsFriend == "1"
+	isFamily := photo.IsFamily == "1"
+	return (isFriend && f.privacy["friends"]) ||
+		(isFamily && f.privacy["family"]) ||
+		(!isFriend && !isFamily && f.privacy["private"])
+}
+
+// photoDimensions returns the photo's original dimensions if available,
+// otherwise the dimensions of the largest size we know about.
+func photoDimensions(photo FlickrPhoto) (int, int) {
+	for _, dims := range [][2]flexString{
+		{photo.OriginalWidth, photo.OriginalHeight},
+		{photo.WidthLarge, photo.HeightLarge},
+		{photo.WidthMedium, photo.HeightMedium},
+	} {
+		width, errW := strconv.Atoi(string(dims[0]))
+		height, errH := strconv.Atoi(string(dims[1]))
+		if errW == nil && errH == nil && width > 0 && height > 0 {
+			return width, height
+		}
+	}
+	return 0, 0
+}
+
+// parseFilterDate parses a date for a date range rule. A bare date (YYYY-MM-DD)
+// means the start of that day, or the end of it if endOfDay is set.
+func parseFilterDate(s string, endOfDay bool) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		if endOfDay {
+			t = t.Add(24*time.Hour - time.Second)
+		}
+		return t, nil
+	}
+	return time.Time{}, NewUsage(fmt.Sprintf("invalid date '%s' (use YYYY-MM-DD or an RFC 3339 timestamp)", s))
+}
+
+// normalizeTag converts a tag to the normalized form Flickr returns in the
+// tags extra: lowercase, with spaces and punctuation removed.
+func normalizeTag(tag string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, strings.ToLower(tag))
+}
+
+func normalizeOwner(owner string) string {
+	return strings.ToLower(strings.TrimSpace(owner))
+}
+
+func normalizeKeyword(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+func normalizedTagSet(tags []string) map[string]bool {
+	return stringSet(tags, normalizeTag)
+}
+
+func stringSet(values []string, normalize func(string) string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		if v = normalize(v); v != "" {
+			set[v] = true
+		}
+	}
+	return set
+}
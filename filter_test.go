@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFilterRulesMatch(t *testing.T) {
+	photo := FlickrPhoto{
+		ID:          "1",
+		Title:       "Sunset over the Bay",
+		Tags:        "sunset sanfrancisco goldengatebridge",
+		License:     "4",
+		Owner:       "12345@N00",
+		Username:    "Alice",
+		DateUpload:  "1700000000", // 2023-11-14T22:13:20Z
+		DateTaken:   "2023-06-01 18:30:00",
+		IsPublic:    "1",
+		WidthLarge:  "1024",
+		HeightLarge: "683",
+	}
+	video := photo
+	video.Media = "video"
+	friendsOnly := photo
+	friendsOnly.IsPublic, friendsOnly.IsFriend = "0", "1"
+	friendsAndFamily := photo
+	friendsAndFamily.IsPublic, friendsAndFamily.IsFriend, friendsAndFamily.IsFamily = "0", "1", "1"
+	private := photo
+	private.IsPublic = "0"
+	original := photo
+	original.OriginalWidth, original.OriginalHeight = "4000", "3000"
+	noDimensions := photo
+	noDimensions.WidthLarge, noDimensions.HeightLarge = "", ""
+
+	tests := []struct {
+		name       string
+		rules      FilterRules
+		dateSource DateSource
+		photo      FlickrPhoto
+		want       bool
+	}{
+		{"empty rules", FilterRules{}, "", photo, true},
+
+		{"include tag", FilterRules{IncludeTags: []string{"sunset"}}, "", photo, true},
+		{"include tag in another form", FilterRules{IncludeTags: []string{"Golden Gate Bridge"}}, "", photo, true},
+		{"include missing tag", FilterRules{IncludeTags: []string{"beach"}}, "", photo, false},
+		{"include any of several tags", FilterRules{IncludeTags: []string{"beach", "sunset"}}, "", photo, true},
+		{"exclude tag", FilterRules{ExcludeTags: []string{"SanFrancisco"}}, "", photo, false},
+		{"exclude missing tag", FilterRules{ExcludeTags: []string{"screenshot"}}, "", photo, true},
+		{"exclude overrides include", FilterRules{IncludeTags: []string{"sunset"}, ExcludeTags: []string{"sanfrancisco"}}, "", photo, false},
+
+		{"title match", FilterRules{TitleMatch: `(?i)^sunset`}, "", photo, true},
+		{"title match is case-sensitive", FilterRules{TitleMatch: `^sunset`}, "", photo, false},
+		{"title match anywhere", FilterRules{TitleMatch: `Bay$`}, "", photo, true},
+		{"title exclude", FilterRules{TitleExclude: `over`}, "", photo, false},
+		{"title exclude without match", FilterRules{TitleExclude: `IMG_\d+`}, "", photo, true},
+
+		{"license", FilterRules{Licenses: []string{"1", " 4 "}}, "", photo, true},
+		{"other license", FilterRules{Licenses: []string{"0"}}, "", photo, false},
+
+		{"photos only", FilterRules{Media: "photos"}, "", photo, true},
+		{"photos only excludes videos", FilterRules{Media: "photo"}, "", video, false},
+		{"videos only", FilterRules{Media: "Video"}, "", video, true},
+		{"videos only excludes photos", FilterRules{Media: "video"}, "", photo, false},
+		{"all media", FilterRules{Media: "all"}, "", video, true},
+
+		{"large enough", FilterRules{MinWidth: 1024, MinHeight: 683}, "", photo, true},
+		{"too narrow", FilterRules{MinWidth: 1025}, "", photo, false},
+		{"too short", FilterRules{MinHeight: 684}, "", photo, false},
+		{"original dimensions preferred", FilterRules{MinWidth: 2000}, "", original, true},
+		{"unknown dimensions", FilterRules{MinWidth: 1}, "", noDimensions, false},
+
+		{"since before upload", FilterRules{Since: "2023-11-14"}, DateSourceUploaded, photo, true},
+		{"since after upload", FilterRules{Since: "2023-11-15"}, DateSourceUploaded, photo, false},
+		{"until day of upload", FilterRules{Until: "2023-11-14"}, DateSourceUploaded, photo, true},
+		{"until before upload", FilterRules{Until: "2023-11-13"}, DateSourceUploaded, photo, false},
+		{"since RFC 3339 after upload", FilterRules{Since: "2023-11-14T22:13:21Z"}, DateSourceUploaded, photo, false},
+		{"until RFC 3339 at upload", FilterRules{Until: "2023-11-14T22:13:20Z"}, DateSourceUploaded, photo, true},
+		{"date range by date taken", FilterRules{Since: "2023-06-01", Until: "2023-06-30"}, DateSourceTaken, photo, true},
+		{"date range excludes by date taken", FilterRules{Since: "2023-07-01"}, DateSourceTaken, photo, false},
+
+		{"owner by NSID", FilterRules{Owners: []string{"12345@n00"}}, "", photo, true},
+		{"owner by username", FilterRules{Owners: []string{"alice"}}, "", photo, true},
+		{"other owner", FilterRules{Owners: []string{"bob"}}, "", photo, false},
+		{"exclude owner by username", FilterRules{ExcludeOwners: []string{"ALICE"}}, "", photo, false},
+		{"exclude other owner", FilterRules{ExcludeOwners: []string{"bob"}}, "", photo, true},
+
+		{"public", FilterRules{Privacy: []string{"public"}}, "", photo, true},
+		{"public excludes friends", FilterRules{Privacy: []string{"public"}}, "", friendsOnly, false},
+		{"friends", FilterRules{Privacy: []string{"friends"}}, "", friendsOnly, true},
+		{"friends excludes public", FilterRules{Privacy: []string{"friends"}}, "", photo, false},
+		{"family matches friends and family", FilterRules{Privacy: []string{"family"}}, "", friendsAndFamily, true},
+		{"family excludes friends only", FilterRules{Privacy: []string{"family"}}, "", friendsOnly, false},
+		{"private", FilterRules{Privacy: []string{"Private"}}, "", private, true},
+		{"private excludes friends", FilterRules{Privacy: []string{"private"}}, "", friendsOnly, false},
+
+		{"every rule passes", FilterRules{
+			IncludeTags: []string{"sunset"},
+			TitleMatch:  "Bay",
+			Licenses:    []string{"4"},
+			Media:       "photo",
+			MinWidth:    800,
+			Since:       "2023-01-01",
+			Owners:      []string{"alice"},
+			Privacy:     []string{"public"},
+		}, DateSourceUploaded, photo, true},
+		{"one rule fails", FilterRules{
+			IncludeTags: []string{"sunset"},
+			TitleMatch:  "Bay",
+			Licenses:    []string{"0"},
+		}, "", photo, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dateSource := tt.dateSource
+			if dateSource == "" {
+				dateSource = DateSourceUploaded
+			}
+			filter, err := tt.rules.Compile(dateSource)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			if got := filter.Match(tt.photo); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterRulesCompileErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules FilterRules
+	}{
+		{"invalid title match", FilterRules{TitleMatch: "("}},
+		{"invalid title exclude", FilterRules{TitleExclude: "[a-"}},
+		{"invalid media", FilterRules{Media: "audio"}},
+		{"invalid privacy", FilterRules{Privacy: []string{"contacts"}}},
+		{"invalid since", FilterRules{Since: "yesterday"}},
+		{"invalid until", FilterRules{Until: "2023-13-01"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.rules.Compile(DateSourceUploaded); !errors.Is(err, ErrUsage) {
+				t.Errorf("Compile error = %v, want a usage error", err)
+			}
+		})
+	}
+}
@@ -6,19 +6,34 @@ import (
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"maps"
 	"net/http"
 	"net/url"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// flickrRESTURL is the Flickr REST API endpoint; tests point it at a fake server.
+var flickrRESTURL = "https://api.flickr.com/services/rest/"
+
+// maxPerPage is the largest page size Flickr's photo list methods allow.
+const maxPerPage = 500
+
+// basePhotoExtras lists the extra fields requested for every photo in a list.
+const basePhotoExtras = "description,date_taken,date_upload,last_update,url_m,url_l,owner_name,media,media_status"
+
 type FlickrClient struct {
 	credentials *Credentials
 	httpClient  *http.Client
+	extras      []string
+	logger      *slog.Logger
 }
 
 type FlickrPhoto struct {
@@ -27,23 +42,147 @@ type FlickrPhoto struct {
 	Description struct {
 		Content string `json:"_content"`
 	} `json:"description"`
-	DateTaken string `json:"datetaken"`
-	URL       string `json:"url_m"`
-	URLLarge  string `json:"url_l"`
-	Secret    string `json:"secret"`
-	Server    string `json:"server"`
-	Farm      int    `json:"farm"`
-	Owner     string `json:"owner"`
-	Username  string `json:"username"`
-}
-
-type FlickrResponse struct {
-	Photos struct {
-		Photo []FlickrPhoto `json:"photo"`
-	} `json:"photos"`
-	Stat    string `json:"stat"`
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	DateTaken   string     `json:"datetaken"`
+	DateUpload  string     `json:"dateupload"`
+	LastUpdate  string     `json:"lastupdate"`
+	URL         string     `json:"url_m"`
+	URLLarge    string     `json:"url_l"`
+	Secret      string     `json:"secret"`
+	Server      string     `json:"server"`
+	Farm        int        `json:"farm"`
+	Owner       string     `json:"owner"`
+	Username    string     `json:"username"`
+	OwnerName   string     `json:"ownername"`
+	Media       string     `json:"media"`
+	MediaStatus string     `json:"media_status"`
+	IsPublic    flexString `json:"ispublic"`
+	IsFriend    flexString `json:"isfriend"`
+	IsFamily    flexString `json:"isfamily"`
+
+	// Optional extras; see ItemExtras
+	Tags      string     `json:"tags"`
+	Latitude  flexString `json:"latitude"`
+	Longitude flexString `json:"longitude"`
+	License   flexString `json:"license"`
+	Views     flexString `json:"views"`
+
+	// Dimensions, returned alongside the url_m and url_l extras and the o_dims extra
+	WidthMedium    flexString `json:"width_m"`
+	HeightMedium   flexString `json:"height_m"`
+	WidthLarge     flexString `json:"width_l"`
+	HeightLarge    flexString `json:"height_l"`
+	OriginalWidth  flexString `json:"o_width"`
+	OriginalHeight flexString `json:"o_height"`
+
+	// EXIF is populated separately, via flickr.photos.getExif
+	EXIF *PhotoEXIF `json:"-"`
+	// Video is populated separately for videos, via flickr.photos.getSizes
+	Video *PhotoVideo `json:"-"`
+	// Comments are populated separately, via flickr.photos.comments.getList
+	Comments []FlickrComment `json:"-"`
+}
+
+// IsNonPublic reports whether Flickr marked the photo as not public: visible only to
+// the owner's friends, family, or the owner themselves.
+func (p FlickrPhoto) IsNonPublic() bool {
+	return p.IsPublic == "0"
+}
+
+// OwnerDisplayName returns the photo owner's username. Depending on the API method,
+// Flickr returns it as either "username" or "ownername" (from the owner_name extra).
+func (p FlickrPhoto) OwnerDisplayName() string {
+	if p.Username != "" {
+		return p.Username
+	}
+	return p.OwnerName
+}
+
+// FlickrSize describes one of the available sizes of a photo or video.
+type FlickrSize struct {
+	Label  string     `json:"label"`
+	Width  flexString `json:"width"`
+	Height flexString `json:"height"`
+	Source string     `json:"source"`
+	URL    string     `json:"url"`
+	Media  string     `json:"media"`
+}
+
+// FlickrPerson holds profile information about a Flickr user, from flickr.people.getInfo.
+type FlickrPerson struct {
+	NSID        string
+	Username    string
+	RealName    string
+	PathAlias   string
+	Location    string
+	Description string
+	ProfileURL  string
+	IconFarm    int
+	IconServer  string
+}
+
+// DisplayName returns the user's real name and username, or just the username
+// if they haven't set a real name.
+func (p *FlickrPerson) DisplayName() string {
+	if p.RealName == "" || p.RealName == p.Username {
+		return p.Username
+	}
+	return fmt.Sprintf("%s (%s)", p.RealName, p.Username)
+}
+
+// BuddyIconURL returns the URL of the user's buddy icon (avatar), or Flickr's
+// default buddy icon if they haven't set one.
+// See https://www.flickr.com/services/api/misc.buddyicons.html
+func (p *FlickrPerson) BuddyIconURL() string {
+	if p.IconServer == "" || p.IconServer == "0" {
+		return "https://www.flickr.com/images/buddyicon.gif"
+	}
+	return fmt.Sprintf("https://farm%d.staticflickr.com/%s/buddyicons/%s.jpg", p.IconFarm, p.IconServer, p.NSID)
+}
+
+// PhotosURL returns the user's photostream URL, preferring their path alias
+// (custom URL) over their NSID.
+func (p *FlickrPerson) PhotosURL() string {
+	return userPhotosURL(p.NSID, p.PathAlias)
+}
+
+func userPhotosURL(nsid, pathAlias string) string {
+	id := nsid
+	if pathAlias != "" {
+		id = pathAlias
+	}
+	return fmt.Sprintf("https://www.flickr.com/photos/%s/", url.PathEscape(id))
+}
+
+// FlickrGroup holds information about a Flickr group.
+type FlickrGroup struct {
+	NSID string
+	Name string
+}
+
+func groupPoolURL(groupID string) string {
+	return fmt.Sprintf("https://www.flickr.com/groups/%s/pool/", url.PathEscape(groupID))
+}
+
+// flexString decodes a JSON string or number into a string. The Flickr API is
+// inconsistent about quoting numeric fields.
+type flexString string
+
+func (f *flexString) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*f = ""
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*f = flexString(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*f = flexString(n.String())
+	return nil
 }
 
 func NewFlickrClient(creds *Credentials) *FlickrClient {
@@ -52,46 +191,61 @@ func NewFlickrClient(creds *Credentials) *FlickrClient {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		logger: slog.Default(),
 	}
 }
 
-func (c *FlickrClient) GetUserPhotos(userID string, count int) ([]FlickrPhoto, error) {
-	var allPhotos []FlickrPhoto
-	perPage := 500 // Maximum allowed by Flickr API
-	page := 1
-
-	// Use authenticated method if OAuth credentials are available
-	useAuth := c.credentials.OAuthToken != "" && c.credentials.OAuthTokenSecret != ""
+// SetLogger sets the logger used for this client's requests, typically one annotated
+// with the feed being generated.
+func (c *FlickrClient) SetLogger(logger *slog.Logger) {
+	c.logger = logger
+}
 
-	for len(allPhotos) < count {
-		// Calculate how many photos to request for this page
-		remaining := count - len(allPhotos)
-		if remaining > perPage {
-			remaining = perPage
+// AddExtras requests additional extra fields for every photo fetched by this client.
+func (c *FlickrClient) AddExtras(extras ...string) {
+	for _, extra := range extras {
+		if !slices.Contains(c.extras, extra) {
+			c.extras = append(c.extras, extra)
 		}
+	}
+}
 
-		var photos []FlickrPhoto
-		var hasMore bool
-		var err error
+func (c *FlickrClient) photoExtras() string {
+	if len(c.extras) == 0 {
+		return basePhotoExtras
+	}
+	return basePhotoExtras + "," + strings.Join(c.extras, ",")
+}
 
-		if useAuth {
-			photos, hasMore, err = c.getUserPhotosPageAuth(userID, remaining, page)
-		} else {
-			photos, hasMore, err = c.getUserPhotosPagePublic(userID, remaining, page)
-		}
+func (c *FlickrClient) GetUserPhotos(userID string, count int) ([]FlickrPhoto, error) {
+	return c.getUserPhotos(userID, count, 0)
+}
 
-		if err != nil {
-			return nil, err
-		}
+// GetOwnPhotos fetches the authenticated user's photos, including private ones.
+// A non-zero privacyFilter limits results to one privacy level; see the privacy_filter
+// argument of https://www.flickr.com/services/api/flickr.people.getPhotos.html
+func (c *FlickrClient) GetOwnPhotos(userID string, count, privacyFilter int) ([]FlickrPhoto, error) {
+	if !c.credentials.HasOAuth() {
+		return nil, NewUsage("fetching your own photostream requires OAuth authentication. Run 'flickr-rss auth' first")
+	}
+	return c.getUserPhotos(userID, count, privacyFilter)
+}
 
-		allPhotos = append(allPhotos, photos...)
+func (c *FlickrClient) getUserPhotos(userID string, count, privacyFilter int) ([]FlickrPhoto, error) {
+	method, params := c.userPhotosMethod(userID, privacyFilter)
+	params["extras"] = c.photoExtras()
 
-		// Stop if we have enough photos or no more pages
-		if len(allPhotos) >= count || !hasMore || len(photos) == 0 {
-			break
+	var allPhotos []FlickrPhoto
+	err := c.forEachPhotoPage(method, params, "photos", min(count, maxPerPage), 1, func(page *RawPhotoPage) (bool, error) {
+		photos, err := page.decode(method)
+		if err != nil {
+			return false, err
 		}
-
-		page++
+		allPhotos = append(allPhotos, photos...)
+		return len(allPhotos) < count, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Trim to exact count requested
@@ -102,387 +256,625 @@ func (c *FlickrClient) GetUserPhotos(userID string, count int) ([]FlickrPhoto, e
 	return allPhotos, nil
 }
 
-func (c *FlickrClient) getUserPhotosPagePublic(userID string, perPage, page int) ([]FlickrPhoto, bool, error) {
-	baseURL := "https://api.flickr.com/services/rest/"
-
-	params := url.Values{}
-	params.Set("method", "flickr.people.getPublicPhotos")
-	params.Set("api_key", c.credentials.APIKey)
-	params.Set("user_id", userID)
-	params.Set("format", "json")
-	params.Set("nojsoncallback", "1")
-	params.Set("per_page", strconv.Itoa(perPage))
-	params.Set("page", strconv.Itoa(page))
-	params.Set("extras", "description,date_taken,url_m,url_l,owner_name")
-
-	reqURL := baseURL + "?" + params.Encode()
-
-	resp, err := c.httpClient.Get(reqURL)
-	if err != nil {
-		return nil, false, WrapFlickrAPI(err, "failed to make API request")
+// userPhotosMethod returns the method and parameters listing a user's photos. With OAuth
+// credentials, it's flickr.people.getPhotos, which includes photos the authenticated user
+// is allowed to see; otherwise it's flickr.people.getPublicPhotos.
+func (c *FlickrClient) userPhotosMethod(userID string, privacyFilter int) (string, map[string]string) {
+	params := map[string]string{"user_id": userID}
+	if !c.credentials.HasOAuth() {
+		return "flickr.people.getPublicPhotos", params
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, false, ClassifyFlickrError(resp.StatusCode, 0, fmt.Sprintf("API request failed with status %d", resp.StatusCode))
+	if privacyFilter != 0 {
+		params["privacy_filter"] = strconv.Itoa(privacyFilter)
 	}
+	return "flickr.people.getPhotos", params
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, false, WrapFlickrAPI(err, "failed to read response body")
-	}
+// forEachPhotoPage fetches a photo list method's pages in order, starting from page
+// first, and passes each to fn until the list ends or fn returns false. The page size
+// stays constant across pages, since Flickr computes each page's offset from it.
+func (c *FlickrClient) forEachPhotoPage(method string, params map[string]string, listKey string, perPage, first int, fn func(page *RawPhotoPage) (bool, error)) error {
+	for n := first; ; n++ {
+		pageParams := maps.Clone(params)
+		pageParams["per_page"] = strconv.Itoa(perPage)
+		pageParams["page"] = strconv.Itoa(n)
 
-	var flickrResp struct {
-		Photos struct {
-			Photo []FlickrPhoto `json:"photo"`
-			Page  int           `json:"page"`
-			Pages int           `json:"pages"`
-			Total interface{}   `json:"total"`
-		} `json:"photos"`
-		Stat    string `json:"stat"`
-		Code    int    `json:"code"`
-		Message string `json:"message"`
+		page, err := c.GetRawPhotoPage(method, pageParams, listKey)
+		if err != nil {
+			return err
+		}
+		more, err := fn(page)
+		if err != nil {
+			return err
+		}
+		if !more || page.Last() {
+			return nil
+		}
 	}
+}
 
-	if err := json.Unmarshal(body, &flickrResp); err != nil {
-		return nil, false, WrapFlickrAPI(err, "failed to parse JSON response")
+// RawPhotoPage is one page of a photo list, keeping each photo's complete JSON.
+type RawPhotoPage struct {
+	Photos []json.RawMessage
+	Page   int
+	Pages  int
+}
+
+// GetRawPhotoPage fetches one page of a photo list method, such as flickr.people.getPhotos
+// or flickr.photosets.getPhotos, whose response holds the list under listKey ("photos"
+// or "photoset"). Unlike the other photo list methods, it keeps every field Flickr returns.
+func (c *FlickrClient) GetRawPhotoPage(method string, params map[string]string, listKey string) (*RawPhotoPage, error) {
+	var result map[string]json.RawMessage
+	if err := c.call(method, params, &result); err != nil {
+		return nil, err
 	}
 
-	if flickrResp.Stat != "ok" {
-		if flickrResp.Message != "" {
-			return nil, false, ClassifyFlickrError(resp.StatusCode, flickrResp.Code, flickrResp.Message)
-		}
-		return nil, false, NewFlickrAPI(fmt.Sprintf("Flickr API returned error status: %s", flickrResp.Stat))
+	var list struct {
+		Photo []json.RawMessage `json:"photo"`
+		Page  flexString        `json:"page"`
+		Pages flexString        `json:"pages"`
+	}
+	if err := json.Unmarshal(result[listKey], &list); err != nil {
+		return nil, WrapFlickrAPI(err, fmt.Sprintf("failed to parse %s response", method))
 	}
 
-	hasMore := flickrResp.Photos.Page < flickrResp.Photos.Pages
-	return flickrResp.Photos.Photo, hasMore, nil
+	page, _ := strconv.Atoi(string(list.Page))
+	pages, _ := strconv.Atoi(string(list.Pages))
+	return &RawPhotoPage{Photos: list.Photo, Page: page, Pages: pages}, nil
 }
 
-func (c *FlickrClient) getUserPhotosPageAuth(userID string, perPage, page int) ([]FlickrPhoto, bool, error) {
-	baseURL := "https://api.flickr.com/services/rest/"
+// Last reports whether this is the last page of its photo list.
+func (p *RawPhotoPage) Last() bool {
+	return p.Page >= p.Pages || len(p.Photos) == 0
+}
 
-	oauthParams := map[string]string{
-		"oauth_consumer_key":     c.credentials.APIKey,
-		"oauth_nonce":            c.generateNonce(),
-		"oauth_signature_method": "HMAC-SHA1",
-		"oauth_timestamp":        strconv.FormatInt(time.Now().Unix(), 10),
-		"oauth_token":            c.credentials.OAuthToken,
-		"oauth_version":          "1.0",
+// decode parses the page's photos, which were returned by method.
+func (p *RawPhotoPage) decode(method string) ([]FlickrPhoto, error) {
+	photos := make([]FlickrPhoto, len(p.Photos))
+	for i, raw := range p.Photos {
+		if err := json.Unmarshal(raw, &photos[i]); err != nil {
+			return nil, WrapFlickrAPI(err, fmt.Sprintf("failed to parse %s response", method))
+		}
 	}
+	return photos, nil
+}
 
-	apiParams := map[string]string{
-		"method":         "flickr.people.getPhotos",
-		"format":         "json",
-		"nojsoncallback": "1",
-		"user_id":        userID,
-		"per_page":       strconv.Itoa(perPage),
-		"page":           strconv.Itoa(page),
-		"extras":         "description,date_taken,url_m,url_l,owner_name",
+func (c *FlickrClient) FindUserByUsername(username string) (string, error) {
+	var result struct {
+		User struct {
+			ID string `json:"nsid"`
+		} `json:"user"`
 	}
 
-	// Combine all parameters for signature
-	allParams := make(map[string]string)
-	for k, v := range oauthParams {
-		allParams[k] = v
+	if err := c.callPublic("flickr.people.findByUsername", map[string]string{"username": username}, &result); err != nil {
+		return "", err
 	}
-	for k, v := range apiParams {
-		allParams[k] = v
+
+	return result.User.ID, nil
+}
+
+func (c *FlickrClient) LookupUserByURL(profileURL string) (string, error) {
+	var result struct {
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
 	}
 
-	signature := c.generateSignature("GET", baseURL, allParams, c.credentials.OAuthTokenSecret)
-	oauthParams["oauth_signature"] = signature
+	if err := c.callPublic("flickr.urls.lookupUser", map[string]string{"url": profileURL}, &result); err != nil {
+		return "", err
+	}
 
-	authHeader := c.buildAuthHeader(oauthParams)
+	return result.User.ID, nil
+}
 
-	// Build URL with API parameters only
-	params := url.Values{}
-	for k, v := range apiParams {
-		params.Set(k, v)
+func (c *FlickrClient) GetUserInfo(userID string) (*FlickrPerson, error) {
+	var result struct {
+		Person struct {
+			NSID       string     `json:"nsid"`
+			IconServer flexString `json:"iconserver"`
+			IconFarm   int        `json:"iconfarm"`
+			PathAlias  string     `json:"path_alias"`
+			Username   struct {
+				Content string `json:"_content"`
+			} `json:"username"`
+			RealName struct {
+				Content string `json:"_content"`
+			} `json:"realname"`
+			Location struct {
+				Content string `json:"_content"`
+			} `json:"location"`
+			Description struct {
+				Content string `json:"_content"`
+			} `json:"description"`
+			ProfileURL struct {
+				Content string `json:"_content"`
+			} `json:"profileurl"`
+		} `json:"person"`
 	}
-	reqURL := baseURL + "?" + params.Encode()
 
-	req, err := http.NewRequest("GET", reqURL, nil)
-	if err != nil {
-		return nil, false, WrapFlickrAPI(err, "failed to create request")
+	if err := c.callPublic("flickr.people.getInfo", map[string]string{"user_id": userID}, &result); err != nil {
+		return nil, err
 	}
-	req.Header.Set("Authorization", authHeader)
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, false, WrapFlickrAPI(err, "failed to make API request")
+	return &FlickrPerson{
+		NSID:        result.Person.NSID,
+		Username:    result.Person.Username.Content,
+		RealName:    result.Person.RealName.Content,
+		PathAlias:   result.Person.PathAlias,
+		Location:    result.Person.Location.Content,
+		Description: result.Person.Description.Content,
+		ProfileURL:  result.Person.ProfileURL.Content,
+		IconFarm:    result.Person.IconFarm,
+		IconServer:  string(result.Person.IconServer),
+	}, nil
+}
+
+// ContactsPhotosOptions correspond to the optional parameters of flickr.photos.getContactsPhotos.
+type ContactsPhotosOptions struct {
+	// JustFriends limits results to friends and family, excluding other contacts.
+	JustFriends bool
+	// SinglePhoto returns only the latest photo from each contact.
+	SinglePhoto bool
+	// IncludeSelf includes the authenticated user's own photos.
+	IncludeSelf bool
+}
+
+func (c *FlickrClient) GetContactsPhotos(count int, opts ContactsPhotosOptions) ([]FlickrPhoto, error) {
+	if !c.credentials.HasOAuth() {
+		return nil, NewUsage("friends & family photos require OAuth authentication. Run 'flickr-rss auth' first")
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, false, ClassifyFlickrError(resp.StatusCode, 0, fmt.Sprintf("API request failed with status %d", resp.StatusCode))
+	// Limit to maximum supported by API
+	if count > 50 {
+		count = 50
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, false, WrapFlickrAPI(err, "failed to read response body")
+	params := map[string]string{
+		"count":  strconv.Itoa(count),
+		"extras": c.photoExtras(),
+	}
+	if opts.JustFriends {
+		params["just_friends"] = "1"
+	}
+	if opts.SinglePhoto {
+		params["single_photo"] = "1"
+	}
+	if opts.IncludeSelf {
+		params["include_self"] = "1"
 	}
 
-	var flickrResp struct {
+	var result struct {
 		Photos struct {
 			Photo []FlickrPhoto `json:"photo"`
-			Page  int           `json:"page"`
-			Pages int           `json:"pages"`
-			Total interface{}   `json:"total"`
 		} `json:"photos"`
-		Stat    string `json:"stat"`
-		Code    int    `json:"code"`
-		Message string `json:"message"`
+	}
+	if err := c.call("flickr.photos.getContactsPhotos", params, &result); err != nil {
+		return nil, err
 	}
 
-	if err := json.Unmarshal(body, &flickrResp); err != nil {
-		return nil, false, WrapFlickrAPI(err, "failed to parse JSON response")
+	return result.Photos.Photo, nil
+}
+
+// LookupGroupByURL returns the NSID of the group at the given Flickr group URL.
+func (c *FlickrClient) LookupGroupByURL(groupURL string) (string, error) {
+	var result struct {
+		Group struct {
+			ID string `json:"id"`
+		} `json:"group"`
 	}
 
-	if flickrResp.Stat != "ok" {
-		if flickrResp.Message != "" {
-			return nil, false, ClassifyFlickrError(resp.StatusCode, flickrResp.Code, flickrResp.Message)
-		}
-		return nil, false, NewFlickrAPI(fmt.Sprintf("Flickr API returned error status: %s", flickrResp.Stat))
+	if err := c.callPublic("flickr.urls.lookupGroup", map[string]string{"url": groupURL}, &result); err != nil {
+		return "", err
 	}
 
-	hasMore := flickrResp.Photos.Page < flickrResp.Photos.Pages
-	return flickrResp.Photos.Photo, hasMore, nil
+	return result.Group.ID, nil
 }
 
-func (c *FlickrClient) FindUserByUsername(username string) (string, error) {
-	baseURL := "https://api.flickr.com/services/rest/"
+func (c *FlickrClient) GetGroupInfo(groupID string) (*FlickrGroup, error) {
+	var result struct {
+		Group struct {
+			NSID string `json:"nsid"`
+			Name struct {
+				Content string `json:"_content"`
+			} `json:"name"`
+		} `json:"group"`
+	}
 
-	params := url.Values{}
-	params.Set("method", "flickr.people.findByUsername")
-	params.Set("api_key", c.credentials.APIKey)
-	params.Set("username", username)
-	params.Set("format", "json")
-	params.Set("nojsoncallback", "1")
+	if err := c.callPublic("flickr.groups.getInfo", map[string]string{"group_id": groupID}, &result); err != nil {
+		return nil, err
+	}
 
-	reqURL := baseURL + "?" + params.Encode()
+	return &FlickrGroup{
+		NSID: result.Group.NSID,
+		Name: result.Group.Name.Content,
+	}, nil
+}
 
-	resp, err := c.httpClient.Get(reqURL)
-	if err != nil {
-		return "", WrapFlickrAPI(err, "failed to make API request")
+// GetGroupPhotos fetches the latest photos in a group's pool.
+func (c *FlickrClient) GetGroupPhotos(groupID string, count int) ([]FlickrPhoto, error) {
+	var allPhotos []FlickrPhoto
+	page := 1
+
+	// Page size must stay constant across pages, since Flickr computes each page's offset from it
+	perPage := min(count, maxPerPage)
+
+	for len(allPhotos) < count {
+		var result struct {
+			Photos struct {
+				Photo []FlickrPhoto `json:"photo"`
+				Page  int           `json:"page"`
+				Pages int           `json:"pages"`
+			} `json:"photos"`
+		}
+
+		params := map[string]string{
+			"group_id": groupID,
+			"per_page": strconv.Itoa(perPage),
+			"page":     strconv.Itoa(page),
+			"extras":   c.photoExtras(),
+		}
+		if err := c.callPublic("flickr.groups.pools.getPhotos", params, &result); err != nil {
+			return nil, err
+		}
+
+		allPhotos = append(allPhotos, result.Photos.Photo...)
+
+		// Stop if we have enough photos or no more pages
+		if len(allPhotos) >= count || result.Photos.Page >= result.Photos.Pages || len(result.Photos.Photo) == 0 {
+			break
+		}
+
+		page++
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", ClassifyFlickrError(resp.StatusCode, 0, fmt.Sprintf("API request failed with status %d", resp.StatusCode))
+	// Trim to exact count requested
+	if len(allPhotos) > count {
+		allPhotos = allPhotos[:count]
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", WrapFlickrAPI(err, "failed to read response body")
+	return allPhotos, nil
+}
+
+// FlickrContact is one of the authenticated user's contacts.
+type FlickrContact struct {
+	NSID     string `json:"nsid"`
+	Username string `json:"username"`
+	Friend   int    `json:"friend"`
+	Family   int    `json:"family"`
+}
+
+// GetContacts lists all of the authenticated user's contacts.
+func (c *FlickrClient) GetContacts() ([]FlickrContact, error) {
+	var contacts []FlickrContact
+	page := 1
+
+	for {
+		var result struct {
+			Contacts struct {
+				Contact []FlickrContact `json:"contact"`
+				Page    flexString      `json:"page"`
+				Pages   flexString      `json:"pages"`
+			} `json:"contacts"`
+		}
+
+		params := map[string]string{
+			"per_page": "1000", // Maximum allowed by Flickr API
+			"page":     strconv.Itoa(page),
+		}
+		if err := c.call("flickr.contacts.getList", params, &result); err != nil {
+			return nil, err
+		}
+
+		contacts = append(contacts, result.Contacts.Contact...)
+
+		pages, _ := strconv.Atoi(string(result.Contacts.Pages))
+		if page >= pages || len(result.Contacts.Contact) == 0 {
+			break
+		}
+		page++
 	}
 
+	return contacts, nil
+}
+
+// TestLogin returns the NSID and username of the authenticated user.
+func (c *FlickrClient) TestLogin() (string, string, error) {
 	var result struct {
 		User struct {
-			ID string `json:"nsid"`
+			ID       string `json:"id"`
+			Username struct {
+				Content string `json:"_content"`
+			} `json:"username"`
 		} `json:"user"`
-		Stat    string `json:"stat"`
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return "", WrapFlickrAPI(err, "failed to parse JSON response")
 	}
 
-	if result.Stat != "ok" {
-		if result.Message != "" {
-			return "", ClassifyFlickrError(resp.StatusCode, result.Code, result.Message)
-		}
-		return "", NewFlickrAPI(fmt.Sprintf("Flickr API returned error status: %s", result.Stat))
+	if err := c.call("flickr.test.login", nil, &result); err != nil {
+		return "", "", err
 	}
 
-	return result.User.ID, nil
+	return result.User.ID, result.User.Username.Content, nil
 }
 
-func (c *FlickrClient) LookupUserByURL(profileURL string) (string, error) {
-	baseURL := "https://api.flickr.com/services/rest/"
-
-	params := url.Values{}
-	params.Set("method", "flickr.urls.lookupUser")
-	params.Set("api_key", c.credentials.APIKey)
-	params.Set("url", profileURL)
-	params.Set("format", "json")
-	params.Set("nojsoncallback", "1")
-
-	reqURL := baseURL + "?" + params.Encode()
+// Echo calls flickr.test.echo, which verifies that the API key is accepted.
+func (c *FlickrClient) Echo() error {
+	var result struct{}
+	return c.call("flickr.test.echo", nil, &result)
+}
 
-	resp, err := c.httpClient.Get(reqURL)
+// ServerTime returns the time reported in the Date header of a response from the
+// Flickr API server, for checking the local clock. OAuth requests are rejected if
+// the local clock is too far off.
+func (c *FlickrClient) ServerTime() (time.Time, error) {
+	resp, err := c.httpClient.Head(flickrRESTURL)
 	if err != nil {
-		return "", WrapFlickrAPI(err, "failed to make API request")
+		return time.Time{}, WrapFlickrAPI(err, "failed to contact Flickr API server")
 	}
-	defer resp.Body.Close()
+	resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", ClassifyFlickrError(resp.StatusCode, 0, fmt.Sprintf("API request failed with status %d", resp.StatusCode))
+	date := resp.Header.Get("Date")
+	if date == "" {
+		return time.Time{}, NewFlickrAPI("Flickr API server response has no Date header")
 	}
-
-	body, err := io.ReadAll(resp.Body)
+	t, err := http.ParseTime(date)
 	if err != nil {
-		return "", WrapFlickrAPI(err, "failed to read response body")
-	}
-
-	var result struct {
-		User struct {
-			ID string `json:"id"`
-		} `json:"user"`
-		Stat    string `json:"stat"`
-		Code    int    `json:"code"`
-		Message string `json:"message"`
+		return time.Time{}, WrapFlickrAPI(err, fmt.Sprintf("failed to parse Flickr API server date '%s'", date))
 	}
+	return t, nil
+}
 
-	if err := json.Unmarshal(body, &result); err != nil {
-		return "", WrapFlickrAPI(err, "failed to parse JSON response")
+// GetPhotoEXIF fetches a summary of the photo's EXIF data.
+func (c *FlickrClient) GetPhotoEXIF(photoID, secret string) (*PhotoEXIF, error) {
+	params := map[string]string{
+		"photo_id": photoID,
+		"secret":   secret,
 	}
 
-	if result.Stat != "ok" {
-		if result.Message != "" {
-			return "", ClassifyFlickrError(resp.StatusCode, result.Code, result.Message)
+	var result struct {
+		Photo struct {
+			Camera string `json:"camera"`
+			EXIF   []struct {
+				Tag string `json:"tag"`
+				Raw struct {
+					Content string `json:"_content"`
+				} `json:"raw"`
+				Clean struct {
+					Content string `json:"_content"`
+				} `json:"clean"`
+			} `json:"exif"`
+		} `json:"photo"`
+	}
+
+	if err := c.call("flickr.photos.getExif", params, &result); err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string)
+	for _, e := range result.Photo.EXIF {
+		value := e.Clean.Content
+		if value == "" {
+			value = e.Raw.Content
+		}
+		if _, exists := tags[e.Tag]; !exists && value != "" {
+			tags[e.Tag] = value
 		}
-		return "", NewFlickrAPI(fmt.Sprintf("Flickr API returned error status: %s", result.Stat))
 	}
 
-	return result.User.ID, nil
+	exif := &PhotoEXIF{
+		Camera:       result.Photo.Camera,
+		Lens:         firstNonEmpty(tags["LensModel"], tags["Lens"], tags["LensInfo"]),
+		FocalLength:  tags["FocalLength"],
+		Aperture:     tags["FNumber"],
+		ShutterSpeed: tags["ExposureTime"],
+		ISO:          tags["ISO"],
+	}
+	if exif.Camera == "" {
+		exif.Camera = strings.TrimSpace(tags["Make"] + " " + tags["Model"])
+	}
+	return exif, nil
 }
 
-func (c *FlickrClient) GetUserInfo(userID string) (string, error) {
-	baseURL := "https://api.flickr.com/services/rest/"
-
-	params := url.Values{}
-	params.Set("method", "flickr.people.getInfo")
-	params.Set("api_key", c.credentials.APIKey)
-	params.Set("user_id", userID)
-	params.Set("format", "json")
-	params.Set("nojsoncallback", "1")
+// FlickrComment is a comment on a photo.
+type FlickrComment struct {
+	ID         string     `json:"id"`
+	Author     string     `json:"author"`
+	AuthorName string     `json:"authorname"`
+	RealName   string     `json:"realname"`
+	DateCreate flexString `json:"datecreate"`
+	Permalink  string     `json:"permalink"`
+	Content    string     `json:"_content"`
+}
 
-	reqURL := baseURL + "?" + params.Encode()
+// AuthorDisplayName returns the comment author's real name, falling back to their username.
+func (c FlickrComment) AuthorDisplayName() string {
+	return firstNonEmpty(c.RealName, c.AuthorName, c.Author)
+}
 
-	resp, err := c.httpClient.Get(reqURL)
+// Date returns when the comment was posted, or the zero time if it's unknown.
+func (c FlickrComment) Date() time.Time {
+	unix, err := strconv.ParseInt(string(c.DateCreate), 10, 64)
 	if err != nil {
-		return "", WrapFlickrAPI(err, "failed to make API request")
+		return time.Time{}
 	}
-	defer resp.Body.Close()
+	return time.Unix(unix, 0)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", ClassifyFlickrError(resp.StatusCode, 0, fmt.Sprintf("API request failed with status %d", resp.StatusCode))
+// GetPhotoComments lists the comments on a photo, oldest first.
+func (c *FlickrClient) GetPhotoComments(photoID string) ([]FlickrComment, error) {
+	var result struct {
+		Comments struct {
+			Comment []FlickrComment `json:"comment"`
+		} `json:"comments"`
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", WrapFlickrAPI(err, "failed to read response body")
+	if err := c.call("flickr.photos.comments.getList", map[string]string{"photo_id": photoID}, &result); err != nil {
+		return nil, err
 	}
 
+	return result.Comments.Comment, nil
+}
+
+// GetPhotoSizes lists the available sizes of a photo or video, including playable video sources.
+func (c *FlickrClient) GetPhotoSizes(photoID string) ([]FlickrSize, error) {
 	var result struct {
-		Person struct {
-			Username struct {
-				Content string `json:"_content"`
-			} `json:"username"`
-		} `json:"person"`
-		Stat    string `json:"stat"`
-		Code    int    `json:"code"`
-		Message string `json:"message"`
+		Sizes struct {
+			Size []FlickrSize `json:"size"`
+		} `json:"sizes"`
 	}
 
-	if err := json.Unmarshal(body, &result); err != nil {
-		return "", WrapFlickrAPI(err, "failed to parse JSON response")
+	if err := c.call("flickr.photos.getSizes", map[string]string{"photo_id": photoID}, &result); err != nil {
+		return nil, err
 	}
 
-	if result.Stat != "ok" {
-		if result.Message != "" {
-			return "", ClassifyFlickrError(resp.StatusCode, result.Code, result.Message)
-		}
-		return "", NewFlickrAPI(fmt.Sprintf("Flickr API returned error status: %s", result.Stat))
-	}
+	return result.Sizes.Size, nil
+}
+
+// flickrStat is the status envelope included in every Flickr API response.
+type flickrStat struct {
+	Stat    string `json:"stat"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
 
-	return result.Person.Username.Content, nil
+// call invokes the given Flickr API method and decodes its JSON response into result.
+// The request is signed with OAuth if the client has OAuth credentials, so it can
+// return what the authenticated user is allowed to see.
+func (c *FlickrClient) call(method string, apiParams map[string]string, result interface{}) error {
+	return c.request(method, apiParams, c.credentials.HasOAuth(), result)
 }
 
-func (c *FlickrClient) GetContactsPhotos(count int) ([]FlickrPhoto, error) {
-	// Limit to maximum supported by API
-	if count > 50 {
-		count = 50
-	}
+// callPublic invokes a Flickr API method with only the API key, for lookups of public
+// data. It's never signed with OAuth, so it keeps working if the OAuth token is revoked,
+// and it returns the same data for everyone.
+func (c *FlickrClient) callPublic(method string, apiParams map[string]string, result interface{}) error {
+	return c.request(method, apiParams, false, result)
+}
 
-	baseURL := "https://api.flickr.com/services/rest/"
+// request performs a call, signed with OAuth if signed is set. Every request is logged:
+// at debug level if it succeeds, and at info level if it fails, since callers decide
+// whether a failure is worth a warning.
+func (c *FlickrClient) request(method string, apiParams map[string]string, signed bool, result interface{}) error {
+	start := time.Now()
+	httpStatus, err := c.doCall(method, apiParams, signed, result)
+	metrics.ObserveAPIRequest(method, time.Since(start), err)
 
-	oauthParams := map[string]string{
-		"oauth_consumer_key":     c.credentials.APIKey,
-		"oauth_nonce":            c.generateNonce(),
-		"oauth_signature_method": "HMAC-SHA1",
-		"oauth_timestamp":        strconv.FormatInt(time.Now().Unix(), 10),
-		"oauth_token":            c.credentials.OAuthToken,
-		"oauth_version":          "1.0",
+	attrs := []any{
+		slog.String("method", method),
+		slog.Duration("duration", time.Since(start)),
+		slog.Int("http_status", httpStatus),
+	}
+	if userID := apiParams["user_id"]; userID != "" {
+		attrs = append(attrs, slog.String("user_id", userID))
+	}
+	if page := apiParams["page"]; page != "" {
+		attrs = append(attrs, slog.String("page", page))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error_class", ErrorClass(err)), slog.Any("error", err))
+		c.logger.Info("Flickr API request failed", attrs...)
+	} else {
+		c.logger.Debug("Flickr API request", attrs...)
 	}
 
-	apiParams := map[string]string{
-		"method":         "flickr.photos.getContactsPhotos",
+	return err
+}
+
+// doCall performs the request for request, returning the HTTP status code of the
+// response, or 0 if no response was received.
+func (c *FlickrClient) doCall(method string, apiParams map[string]string, signed bool, result interface{}) (int, error) {
+	allAPIParams := map[string]string{
+		"method":         method,
 		"format":         "json",
 		"nojsoncallback": "1",
-		"count":          strconv.Itoa(count),
-		"just_friends":   "1",
-		"extras":         "description,date_taken,url_m,url_l,owner_name",
-	}
-
-	// Combine all parameters for signature
-	allParams := make(map[string]string)
-	for k, v := range oauthParams {
-		allParams[k] = v
 	}
 	for k, v := range apiParams {
-		allParams[k] = v
+		allAPIParams[k] = v
 	}
 
-	signature := c.generateSignature("GET", baseURL, allParams, c.credentials.OAuthTokenSecret)
-	oauthParams["oauth_signature"] = signature
-
-	authHeader := c.buildAuthHeader(oauthParams)
-
-	// Build URL with API parameters only
 	params := url.Values{}
-	for k, v := range apiParams {
+	for k, v := range allAPIParams {
 		params.Set(k, v)
 	}
-	reqURL := baseURL + "?" + params.Encode()
 
-	req, err := http.NewRequest("GET", reqURL, nil)
+	var authHeader string
+	if signed {
+		oauthParams := map[string]string{
+			"oauth_consumer_key":     c.credentials.APIKey,
+			"oauth_nonce":            c.generateNonce(),
+			"oauth_signature_method": "HMAC-SHA1",
+			"oauth_timestamp":        strconv.FormatInt(time.Now().Unix(), 10),
+			"oauth_token":            c.credentials.OAuthToken,
+			"oauth_version":          "1.0",
+		}
+
+		// Combine all parameters for signature
+		sigParams := make(map[string]string)
+		for k, v := range oauthParams {
+			sigParams[k] = v
+		}
+		for k, v := range allAPIParams {
+			sigParams[k] = v
+		}
+
+		oauthParams["oauth_signature"] = c.generateSignature("GET", flickrRESTURL, sigParams, c.credentials.OAuthTokenSecret)
+		authHeader = c.buildAuthHeader(oauthParams)
+	} else {
+		params.Set("api_key", c.credentials.APIKey)
+	}
+
+	req, err := http.NewRequest("GET", flickrRESTURL+"?"+params.Encode(), nil)
 	if err != nil {
-		return nil, WrapFlickrAPI(err, "failed to create request")
+		return 0, WrapFlickrAPI(err, "failed to create request")
+	}
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
 	}
-	req.Header.Set("Authorization", authHeader)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, WrapFlickrAPI(err, "failed to make API request")
+		// The request URL may include the API key, so omit it from the error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, WrapFlickrAPI(err, "failed to make API request")
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, ClassifyFlickrError(resp.StatusCode, 0, fmt.Sprintf("API request failed with status %d", resp.StatusCode))
+		return resp.StatusCode, ClassifyFlickrError(resp.StatusCode, 0, fmt.Sprintf("API request failed with status %d", resp.StatusCode))
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, WrapFlickrAPI(err, "failed to read response body")
+		return resp.StatusCode, WrapFlickrAPI(err, "failed to read response body")
 	}
 
-	var flickrResp FlickrResponse
-	if err := json.Unmarshal(body, &flickrResp); err != nil {
-		return nil, WrapFlickrAPI(err, "failed to parse JSON response")
+	var stat flickrStat
+	if err := json.Unmarshal(body, &stat); err != nil {
+		return resp.StatusCode, WrapFlickrAPI(err, "failed to parse JSON response")
 	}
 
-	if flickrResp.Stat != "ok" {
-		if flickrResp.Message != "" {
-			return nil, ClassifyFlickrError(resp.StatusCode, flickrResp.Code, flickrResp.Message)
+	if stat.Stat != "ok" {
+		if stat.Message != "" {
+			return resp.StatusCode, ClassifyFlickrError(resp.StatusCode, stat.Code, stat.Message)
 		}
-		return nil, NewFlickrAPI(fmt.Sprintf("Flickr API returned error status: %s", flickrResp.Stat))
+		return resp.StatusCode, NewFlickrAPI(fmt.Sprintf("Flickr API returned error status: %s", stat.Stat))
+	}
+
+	if err := json.Unmarshal(body, result); err != nil {
+		return resp.StatusCode, WrapFlickrAPI(err, "failed to parse JSON response")
 	}
 
-	return flickrResp.Photos.Photo, nil
+	return resp.StatusCode, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
 }
 
 func (c *FlickrClient) generateNonce() string {
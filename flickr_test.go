@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// fakeFlickrRequest is a request received by a fake Flickr API server.
+type fakeFlickrRequest struct {
+	Params url.Values
+	Signed bool
+}
+
+// fakeFlickr is a fake Flickr REST API. Each method's handler returns the JSON
+// response body for a request's parameters, without the "stat" field; methods with
+// no handler fail with Flickr's "method not found" error.
+type fakeFlickr struct {
+	t        *testing.T
+	handlers map[string]func(params url.Values) any
+
+	mu       sync.Mutex
+	requests map[string][]fakeFlickrRequest
+}
+
+// newFakeFlickr starts a fake Flickr API server and points the client at it for the
+// rest of the test.
+func newFakeFlickr(t *testing.T, handlers map[string]func(params url.Values) any) *fakeFlickr {
+	t.Helper()
+	f := &fakeFlickr{t: t, handlers: handlers, requests: make(map[string][]fakeFlickrRequest)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	oldURL := flickrRESTURL
+	flickrRESTURL = srv.URL + "/services/rest/"
+	t.Cleanup(func() { flickrRESTURL = oldURL })
+	return f
+}
+
+func (f *fakeFlickr) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	method := params.Get("method")
+
+	f.mu.Lock()
+	f.requests[method] = append(f.requests[method], fakeFlickrRequest{
+		Params: params,
+		Signed: r.Header.Get("Authorization") != "",
+	})
+	f.mu.Unlock()
+
+	body := map[string]any{"stat": "fail", "code": 112, "message": "Method \"" + method + "\" not found"}
+	if handler, ok := f.handlers[method]; ok {
+		data, err := json.Marshal(handler(params))
+		if err != nil {
+			f.t.Errorf("encoding fake %s response: %v", method, err)
+		}
+		body = map[string]any{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			f.t.Errorf("fake %s response must be a JSON object: %v", method, err)
+		}
+		if _, ok := body["stat"]; !ok {
+			body["stat"] = "ok"
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// Requests returns the requests received for method.
+func (f *fakeFlickr) Requests(method string) []fakeFlickrRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[method]
+}
+
+func TestClientSignsOnlyPrivateMethods(t *testing.T) {
+	fake := newFakeFlickr(t, map[string]func(url.Values) any{
+		"flickr.people.findByUsername": func(url.Values) any {
+			return map[string]any{"user": map[string]any{"nsid": "12345@N00"}}
+		},
+		"flickr.urls.lookupUser": func(url.Values) any {
+			return map[string]any{"user": map[string]any{"id": "12345@N00"}}
+		},
+		"flickr.groups.pools.getPhotos": func(url.Values) any {
+			return map[string]any{"photos": map[string]any{"page": 1, "pages": 1, "photo": []any{}}}
+		},
+		"flickr.test.login": func(url.Values) any {
+			return map[string]any{"user": map[string]any{"id": "12345@N00", "username": map[string]any{"_content": "alice"}}}
+		},
+	})
+
+	client := NewFlickrClient(&Credentials{APIKey: "key", APISecret: "secret", OAuthToken: "token", OAuthTokenSecret: "token-secret"})
+	if _, err := client.FindUserByUsername("alice"); err != nil {
+		t.Fatalf("FindUserByUsername: %v", err)
+	}
+	if _, err := client.LookupUserByURL("https://www.flickr.com/photos/alice/"); err != nil {
+		t.Fatalf("LookupUserByURL: %v", err)
+	}
+	if _, err := client.GetGroupPhotos("67890@N00", 10); err != nil {
+		t.Fatalf("GetGroupPhotos: %v", err)
+	}
+	if _, _, err := client.TestLogin(); err != nil {
+		t.Fatalf("TestLogin: %v", err)
+	}
+
+	for method, wantSigned := range map[string]bool{
+		"flickr.people.findByUsername":  false,
+		"flickr.urls.lookupUser":        false,
+		"flickr.groups.pools.getPhotos": false,
+		"flickr.test.login":             true,
+	} {
+		requests := fake.Requests(method)
+		if len(requests) != 1 {
+			t.Errorf("%s: got %d requests, want 1", method, len(requests))
+			continue
+		}
+		req := requests[0]
+		if req.Signed != wantSigned {
+			t.Errorf("%s: signed = %v, want %v", method, req.Signed, wantSigned)
+		}
+		if hasKey := req.Params.Get("api_key") == "key"; hasKey == wantSigned {
+			t.Errorf("%s: api_key parameter present = %v, want %v", method, hasKey, !wantSigned)
+		}
+	}
+}
+
+// newFakeUserFeed starts a fake Flickr API serving the given photos as the photostream
+// of the user "alice" (12345@N00).
+func newFakeUserFeed(t *testing.T, photos ...map[string]any) *fakeFlickr {
+	t.Helper()
+	page := func(url.Values) any {
+		return map[string]any{"photos": map[string]any{"page": 1, "pages": 1, "photo": photos}}
+	}
+	return newFakeFlickr(t, map[string]func(url.Values) any{
+		"flickr.people.findByUsername": func(url.Values) any {
+			return map[string]any{"user": map[string]any{"nsid": "12345@N00"}}
+		},
+		"flickr.people.getInfo": func(url.Values) any {
+			return map[string]any{"person": map[string]any{"nsid": "12345@N00", "username": map[string]any{"_content": "alice"}}}
+		},
+		"flickr.people.getPhotos":       page,
+		"flickr.people.getPublicPhotos": page,
+	})
+}
+
+// fakePhoto returns a photo as a Flickr photo list returns it, public unless
+// isPublic is false.
+func fakePhoto(id string, isPublic bool) map[string]any {
+	public := 0
+	if isPublic {
+		public = 1
+	}
+	return map[string]any{
+		"id": id, "title": "Photo " + id, "owner": "12345@N00", "ownername": "alice",
+		"dateupload": "1709294400", "datetaken": "2024-03-01 12:00:00",
+		"ispublic": public, "isfriend": 1, "isfamily": 0,
+	}
+}
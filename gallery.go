@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// galleryPhoto is a photo as rendered in a gallery page.
+type galleryPhoto struct {
+	Title    string
+	Link     string
+	Owner    string
+	OwnerURL string
+	Date     string
+	ImageURL string
+	ImageSet string
+	Video    bool
+}
+
+var galleryTemplate = template.Must(template.New("gallery").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+{{if .FeedURL}}<link rel="alternate" type="application/rss+xml" title="{{.Title}}" href="{{.FeedURL}}">
+{{end}}<style>
+body { font-family: -apple-system, Helvetica, Arial, sans-serif; color: #222; margin: 0 auto; padding: 16px; max-width: 1200px; }
+header { display: flex; align-items: center; gap: 12px; margin-bottom: 24px; }
+header img { width: 48px; height: 48px; border-radius: 50%; }
+h1 { font-size: 24px; margin: 0; }
+h1 a, figcaption a { color: inherit; text-decoration: none; }
+.description, .meta { color: #666; }
+.grid { display: grid; grid-template-columns: repeat(auto-fill, minmax(240px, 1fr)); gap: 16px; }
+figure { margin: 0; }
+figure img { width: 100%; aspect-ratio: 4 / 3; object-fit: cover; background: #eee; display: block; }
+figcaption { font-size: 14px; margin-top: 6px; }
+.meta { font-size: 12px; }
+</style>
+</head>
+<body>
+<header>
+{{if .IconURL}}<img src="{{.IconURL}}" alt="">{{end}}
+<div>
+<h1><a href="{{.Link}}">{{.Title}}</a></h1>
+{{if .Description}}<div class="description">{{.Description}}</div>{{end}}
+{{if .FeedURL}}<div class="meta"><a href="{{.FeedURL}}">RSS feed</a></div>{{end}}
+</div>
+</header>
+<main class="grid">
+{{range .Photos}}<figure>
+<a href="{{.Link}}"><img src="{{.ImageURL}}"{{if .ImageSet}} srcset="{{.ImageSet}}"{{end}} alt="{{.Title}}" loading="lazy"></a>
+<figcaption><a href="{{.Link}}">{{.Title}}</a>{{if .Video}} (video){{end}}
+<div class="meta">{{if .Owner}}by {{if .OwnerURL}}<a href="{{.OwnerURL}}">{{.Owner}}</a>{{else}}{{.Owner}}{{end}}{{end}}{{if and .Owner .Date}} &middot; {{end}}{{.Date}}</div>
+</figcaption>
+</figure>
+{{end}}</main>
+</body>
+</html>
+`))
+
+// RenderGallery renders the feed as a static HTML page: a grid of thumbnails linking
+// to Flickr, with captions, owner credits, and dates. If feedURL is non-empty, the
+// page links to it as the page's RSS feed.
+func RenderGallery(w io.Writer, feed *RSSFeed, feedURL string) error {
+	photos := make([]galleryPhoto, len(feed.Items))
+	for i, item := range feed.Items {
+		photo := feed.Photos[i]
+		p := galleryPhoto{
+			Title:    firstNonEmpty(item.Title, "Untitled"),
+			Link:     item.Link,
+			Owner:    photo.OwnerDisplayName(),
+			OwnerURL: fmt.Sprintf("https://www.flickr.com/photos/%s/", photo.Owner),
+			Date:     formatItemDate(item),
+			ImageURL: photoThumbnailURL(photo),
+			Video:    photo.IsVideo(),
+		}
+		if photo.Owner == "" {
+			p.OwnerURL = ""
+		}
+		if photo.Server != "" && photo.Secret != "" {
+			p.ImageSet = fmt.Sprintf("%s 1x, %s 2x", p.ImageURL, photoSizeURL(photo, "z"))
+		}
+		photos[i] = p
+	}
+
+	data := map[string]any{
+		"Title":       feed.Title,
+		"Link":        feed.Link,
+		"Description": feed.Description,
+		"FeedURL":     feedURL,
+		"Photos":      photos,
+	}
+	if feed.Image != nil {
+		data["IconURL"] = feed.Image.URL
+	}
+	return galleryTemplate.Execute(w, data)
+}
+
+// galleryFeedURL returns the URL the gallery page links to for the feed: its self URL
+// if it has one, or else the feed file's path relative to the page.
+func galleryFeedURL(cfg FeedConfig) string {
+	if cfg.SelfURL != "" {
+		return cfg.SelfURL
+	}
+	if cfg.Output == "" {
+		return ""
+	}
+	htmlDir, err := filepath.Abs(filepath.Dir(cfg.HTML))
+	if err != nil {
+		return ""
+	}
+	output, err := filepath.Abs(cfg.Output)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(htmlDir, output)
+	if err != nil {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// WriteGallery writes the feed's gallery page to cfg.HTML. Like WriteFeed, it leaves
+// an unchanged page alone, replaces the file atomically, and restricts the permissions
+// of a page showing non-public photos unless the feed is tokenized.
+func WriteGallery(feed *RSSFeed, cfg FeedConfig) error {
+	path := cfg.HTML
+	logger := cfg.Logger()
+
+	restrict := feed.Private && !cfg.Tokenize
+	if restrict {
+		if err := checkPrivateOutput(path); err != nil {
+			return err
+		}
+	}
+
+	var page bytes.Buffer
+	if err := RenderGallery(&page, feed, galleryFeedURL(cfg)); err != nil {
+		return fmt.Errorf("failed to render gallery page: %w", err)
+	}
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, page.Bytes()) {
+		logger.Info("Gallery page is unchanged; not rewriting it", "path", path)
+		return nil
+	}
+
+	perm := os.FileMode(0644)
+	if restrict {
+		perm = 0600
+	}
+
+	logger.Info("Writing gallery page", "path", path)
+	return writeFileAtomic(path, perm, func(w io.Writer) error {
+		if _, err := w.Write(page.Bytes()); err != nil {
+			return WrapFileIO(err, fmt.Sprintf("failed to write gallery page to %s", path))
+		}
+		return nil
+	})
+}
+
+// formatItemDate formats an item's date for display, or returns "" if it has none.
+func formatItemDate(item RSSItem) string {
+	date, err := time.Parse(time.RFC1123Z, item.PubDate)
+	if err != nil {
+		return ""
+	}
+	return date.Format("January 2, 2006")
+}
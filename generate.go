@@ -0,0 +1,705 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// sourceConcurrency bounds the number of feed sources fetched at once.
+const sourceConcurrency = 4
+
+// contactConcurrency bounds the number of contacts' photostreams fetched at once
+// when building a friends & family feed from individual contacts.
+const contactConcurrency = 4
+
+// maxContactsPhotos is the most photos flickr.photos.getContactsPhotos can return.
+const maxContactsPhotos = 50
+
+// FeedConfig describes a feed to generate: where its photos come from, how
+// they're selected and presented, and where the feed is written.
+type FeedConfig struct {
+	// Name identifies the feed in logs and metrics; it defaults to the output path.
+	Name string `yaml:"name"`
+
+	// Users lists users whose photostreams are included, as usernames, NSIDs, or profile URLs.
+	Users []string `yaml:"users"`
+	// Groups lists group pools whose photos are included, as group NSIDs or URLs.
+	Groups []string `yaml:"groups"`
+	// Me includes the authenticated user's own photostream, including non-public photos.
+	Me bool `yaml:"me"`
+	// FriendsFamily includes photos from the authenticated user's friends & family.
+	FriendsFamily bool `yaml:"friends_family"`
+	// FFContacts selects whose photos the friends & family source includes:
+	// "ff" (friends and family; the default), "friends", "family", or "all" contacts.
+	FFContacts string `yaml:"ff_contacts"`
+	// FFSinglePhoto includes only the latest photo from each contact.
+	FFSinglePhoto bool `yaml:"ff_single_photo"`
+	// FFIncludeSelf includes the authenticated user's own photos in the friends & family source.
+	FFIncludeSelf bool `yaml:"ff_include_self"`
+
+	// Count is the number of photos fetched from each source and kept in the feed.
+	// Filters are applied to the fetched photos, so a filtered feed may have fewer.
+	Count      int         `yaml:"count"`
+	DateSource string      `yaml:"date_source"`
+	Include    []string    `yaml:"include"`
+	Filters    FilterRules `yaml:"filters"`
+	SelfURL    string      `yaml:"self_url"`
+	Output     string      `yaml:"output"`
+
+	// MirrorDir is a directory to download the feed's images to; the feed then links to
+	// them under MirrorBaseURL instead of on Flickr. See mirrorPhotoImages.
+	MirrorDir     string `yaml:"mirror_dir"`
+	MirrorBaseURL string `yaml:"mirror_base_url"`
+
+	// Comments appends each photo's most recent comments to its item's description;
+	// CommentsFeed is a file to write a separate feed of the photos' comments to.
+	Comments     bool   `yaml:"comments"`
+	CommentsFeed string `yaml:"comments_feed"`
+
+	// HTML is a static gallery page of the feed's photos, written alongside the feed; see WriteGallery.
+	HTML string `yaml:"html"`
+
+	// WebSubHub is the URL of a WebSub hub advertised in the feed and notified when it changes.
+	WebSubHub string `yaml:"websub_hub"`
+
+	// Tokenize embeds a secret token in the output filename and self URL; see ApplyFeedToken.
+	Tokenize bool `yaml:"tokenize"`
+
+	// OnChange is a shell command run after the feed is written with new photos;
+	// Webhook is a URL POSTed to at the same time. See NotifyChange.
+	OnChange string `yaml:"on_change"`
+	Webhook  string `yaml:"webhook"`
+}
+
+// FeedName identifies the feed in logs and metrics.
+func (cfg FeedConfig) FeedName() string {
+	if cfg.Name != "" {
+		return cfg.Name
+	}
+	if cfg.Output == "" {
+		return "stdout"
+	}
+	return cfg.Output
+}
+
+// Logger returns a logger annotated with the feed's name, for messages about this feed.
+func (cfg FeedConfig) Logger() *slog.Logger {
+	return slog.With(slog.String("feed", cfg.FeedName()))
+}
+
+// MayIncludeNonPublic reports whether the feed's sources can include non-public photos.
+func (cfg FeedConfig) MayIncludeNonPublic() bool {
+	return cfg.FriendsFamily || (cfg.Me && cfg.Filters.AllowsNonPublic())
+}
+
+// feedSource is the result of fetching one of a feed's sources.
+type feedSource struct {
+	info   FeedInfo
+	photos []FlickrPhoto
+}
+
+// BuildFeed fetches photos from each of the feed's sources and assembles them into a feed.
+// When the feed has multiple sources, their photos are de-duplicated, ordered by date,
+// and limited to the feed's count.
+func BuildFeed(creds *Credentials, cfg FeedConfig) (*RSSFeed, error) {
+	ds, err := ParseDateSource(cfg.DateSource)
+	if err != nil {
+		return nil, err
+	}
+
+	extras, err := ParseItemExtras(cfg.Include)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := cfg.Filters.Compile(ds)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Users) == 0 && len(cfg.Groups) == 0 && !cfg.FriendsFamily && !cfg.Me {
+		return nil, NewUsage("at least one username, user ID, profile URL, group, --me, or --ff is required")
+	}
+
+	if cfg.Count <= 0 {
+		return nil, NewUsage("photo count must be greater than zero")
+	}
+
+	if err := creds.Validate(); err != nil {
+		return nil, WrapInputs(err, "invalid credentials")
+	}
+
+	// Verify OAuth credentials are present for friends & family access
+	if cfg.FriendsFamily && !creds.HasOAuth() {
+		return nil, NewUsage("friends & family feed requires OAuth authentication. Run 'flickr-rss auth' first")
+	}
+
+	if cfg.Me && !creds.HasOAuth() {
+		return nil, NewUsage("your own photostream requires OAuth authentication. Run 'flickr-rss auth' first")
+	}
+
+	if (cfg.MirrorDir == "") != (cfg.MirrorBaseURL == "") {
+		return nil, NewUsage("--mirror-dir and --mirror-base-url must be given together")
+	}
+
+	if cfg.WebSubHub != "" && cfg.SelfURL == "" {
+		return nil, NewUsage("a WebSub hub requires the feed's self URL, given with --self-url")
+	}
+
+	switch cfg.FFContacts {
+	case "", "ff", "friends", "family", "all":
+	default:
+		return nil, NewUsage(fmt.Sprintf("invalid friends & family contacts '%s' (must be one of: ff, friends, family, all)", cfg.FFContacts))
+	}
+
+	logger := cfg.Logger()
+	client := NewFlickrClient(creds)
+	client.SetLogger(logger)
+	client.AddExtras(extras.APIExtras()...)
+	client.AddExtras(cfg.Filters.APIExtras()...)
+
+	var fetchers []func() (*feedSource, error)
+	for _, user := range cfg.Users {
+		fetchers = append(fetchers, func() (*feedSource, error) {
+			return fetchUserSource(client, user, cfg.Count)
+		})
+	}
+	if cfg.Me {
+		fetchers = append(fetchers, func() (*feedSource, error) {
+			return fetchMeSource(client, cfg.Count, cfg.Filters.APIPrivacyFilter())
+		})
+	}
+	for _, group := range cfg.Groups {
+		fetchers = append(fetchers, func() (*feedSource, error) {
+			return fetchGroupSource(client, group, cfg.Count)
+		})
+	}
+	if cfg.FriendsFamily {
+		fetchers = append(fetchers, func() (*feedSource, error) {
+			return fetchFriendsFamilySource(client, cfg, ds)
+		})
+	}
+
+	sources := make([]*feedSource, len(fetchers))
+	errs := make([]error, len(fetchers))
+	forEachConcurrently(len(fetchers), sourceConcurrency, func(i int) {
+		sources[i], errs[i] = fetchers[i]()
+	})
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	info, photos := mergeSources(sources, ds)
+	if len(sources) > 1 {
+		logger.Info("Merged sources", "sources", len(sources), "photos", len(photos))
+	}
+	info.SelfURL = cfg.SelfURL
+
+	// Filters only narrow the photos already fetched; no more are fetched to make up
+	// for the ones they drop
+	photos = filter.Apply(photos)
+	logger.Info("Filtered photos", "photos", len(photos))
+
+	if len(photos) > cfg.Count {
+		photos = photos[:cfg.Count]
+	}
+
+	if extras.EXIF {
+		fetchPhotoEXIF(client, photos)
+	}
+	fetchVideoSources(client, photos)
+	if cfg.Comments || cfg.CommentsFeed != "" {
+		fetchPhotoComments(client, photos)
+	}
+
+	// Treat the feed as private whenever non-public photos could appear in it, so its
+	// protection doesn't depend on what happened to be fetched this time
+	private := cfg.MayIncludeNonPublic()
+	for _, photo := range photos {
+		if photo.IsNonPublic() {
+			private = true
+			break
+		}
+	}
+
+	if cfg.MirrorDir != "" {
+		// Mirrored images are served by a web server, so only a feed whose URL is secret
+		// may mirror non-public photos
+		if private && !cfg.Tokenize {
+			return nil, NewUsage("refusing to mirror images of non-public photos, which would be served publicly; use --tokenize to publish the feed at an unguessable URL, or --privacy public")
+		}
+		if err := mirrorPhotoImages(photos, cfg.MirrorDir, cfg.MirrorBaseURL, logger); err != nil {
+			return nil, err
+		}
+	}
+
+	feed := GenerateRSSFeed(photos, info, ds, extras)
+	if cfg.Comments {
+		appendItemComments(feed)
+	}
+	feed.HubURL = cfg.WebSubHub
+	feed.Private = private
+
+	return feed, nil
+}
+
+// mergeSources combines the photos from multiple sources, removing duplicates and
+// ordering them newest-first. A single source's feed info is used as-is; multiple
+// sources produce a combined name and a generic link.
+func mergeSources(sources []*feedSource, ds DateSource) (FeedInfo, []FlickrPhoto) {
+	if len(sources) == 1 {
+		return sources[0].info, sources[0].photos
+	}
+
+	var names []string
+	var photos []FlickrPhoto
+	seen := make(map[string]bool)
+	for _, source := range sources {
+		names = append(names, source.info.Name)
+		for _, photo := range source.photos {
+			if seen[photo.ID] {
+				continue
+			}
+			seen[photo.ID] = true
+			photos = append(photos, photo)
+		}
+	}
+	sortPhotosByDate(photos, ds)
+
+	return FeedInfo{
+		Name: strings.Join(names, ", "),
+		Link: "https://www.flickr.com/",
+	}, photos
+}
+
+// resolveUser returns the NSID of a user given as a profile URL, username, or NSID,
+// along with a name to display for them until their profile is fetched.
+func resolveUser(client *FlickrClient, userInput string) (userID, displayName string, err error) {
+	client.logger.Info("Looking up user", "user", userInput)
+
+	// Check if userInput is a Flickr profile URL
+	if isFlickrProfileURL(userInput) {
+		client.logger.Debug("Detected Flickr profile URL, looking up user", "url", userInput)
+		userID, err = client.LookupUserByURL(userInput)
+		if err != nil {
+			return "", "", WrapFlickrAPI(err, fmt.Sprintf("failed to lookup user from URL '%s'", userInput))
+		}
+		return userID, userID, nil
+	}
+	if containsNonNumeric(userInput) {
+		// Try to find user by username (if it contains non-numeric characters, likely a username)
+		userID, err = client.FindUserByUsername(userInput)
+		if err != nil {
+			return "", "", WrapFlickrAPI(err, fmt.Sprintf("failed to find user by username '%s'", userInput))
+		}
+		return userID, userInput, nil
+	}
+	// Assume it's already a user ID
+	return userInput, userInput, nil
+}
+
+func fetchUserSource(client *FlickrClient, userInput string, count int) (*feedSource, error) {
+	userID, displayName, err := resolveUser(client, userInput)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the user's actual username and photostream URL for the feed's metadata
+	feedLink := userPhotosURL(userID, "")
+	person, err := client.GetUserInfo(userID)
+	if err != nil {
+		client.logger.Warn("Failed to get user info; using user ID for feed link", "user_id", userID, "error", err)
+	} else {
+		if person.Username != "" {
+			displayName = person.Username
+		}
+		feedLink = person.PhotosURL()
+	}
+
+	client.logger.Debug("Resolved user", "user_id", userID, "name", displayName)
+
+	// Fetch latest photos
+	photos, err := client.GetUserPhotos(userID, count)
+	if err != nil {
+		return nil, WrapFlickrAPI(err, fmt.Sprintf("failed to fetch photos for user %s", userID))
+	}
+
+	client.logger.Info("Fetched user photos", "user_id", userID, "name", displayName, "photos", len(photos))
+
+	return &feedSource{
+		info: FeedInfo{
+			Name:   displayName,
+			Link:   feedLink,
+			Person: person,
+		},
+		photos: photos,
+	}, nil
+}
+
+// fetchMeSource fetches the authenticated user's own photostream.
+func fetchMeSource(client *FlickrClient, count, privacyFilter int) (*feedSource, error) {
+	userID, username, err := client.TestLogin()
+	if err != nil {
+		return nil, WrapFlickrAPI(err, "failed to identify the authenticated user")
+	}
+
+	client.logger.Info("Authenticated", "user_id", userID, "username", username)
+
+	info := FeedInfo{
+		Name: username,
+		Link: userPhotosURL(userID, ""),
+	}
+	person, err := client.GetUserInfo(userID)
+	if err != nil {
+		client.logger.Warn("Failed to get user info; using user ID for feed link", "user_id", userID, "error", err)
+	} else {
+		info.Link = person.PhotosURL()
+		info.Person = person
+	}
+
+	photos, err := client.GetOwnPhotos(userID, count, privacyFilter)
+	if err != nil {
+		return nil, WrapFlickrAPI(err, "failed to fetch your photos")
+	}
+
+	client.logger.Info("Fetched your photos", "user_id", userID, "photos", len(photos))
+
+	return &feedSource{
+		info:   info,
+		photos: photos,
+	}, nil
+}
+
+func fetchGroupSource(client *FlickrClient, groupInput string, count int) (*feedSource, error) {
+	groupID := groupInput
+	if isFlickrGroupURL(groupInput) {
+		var err error
+		groupID, err = client.LookupGroupByURL(groupInput)
+		if err != nil {
+			return nil, WrapFlickrAPI(err, fmt.Sprintf("failed to lookup group from URL '%s'", groupInput))
+		}
+	}
+
+	name := groupID
+	group, err := client.GetGroupInfo(groupID)
+	if err != nil {
+		client.logger.Warn("Failed to get group info; using group ID for feed title", "group_id", groupID, "error", err)
+	} else if group.Name != "" {
+		name = group.Name
+	}
+
+	photos, err := client.GetGroupPhotos(groupID, count)
+	if err != nil {
+		return nil, WrapFlickrAPI(err, fmt.Sprintf("failed to fetch photos for group %s", groupID))
+	}
+
+	client.logger.Info("Fetched group photos", "group_id", groupID, "name", name, "photos", len(photos))
+
+	return &feedSource{
+		info: FeedInfo{
+			Name: name,
+			Link: groupPoolURL(groupID),
+		},
+		photos: photos,
+	}, nil
+}
+
+// fetchFriendsFamilySource fetches photos from the authenticated user's contacts. When
+// possible it uses flickr.photos.getContactsPhotos, which is a single request but returns
+// at most 50 photos and can't distinguish friends from family. Otherwise, it lists the
+// user's contacts and merges each matching contact's photostream.
+func fetchFriendsFamilySource(client *FlickrClient, cfg FeedConfig, ds DateSource) (*feedSource, error) {
+	contacts := cfg.FFContacts
+	if contacts == "" {
+		contacts = "ff"
+	}
+
+	info := FeedInfo{
+		Name: "Friends & Family",
+		Link: "https://www.flickr.com/photos/friends/",
+	}
+	switch contacts {
+	case "friends":
+		info.Name = "Friends"
+	case "family":
+		info.Name = "Family"
+	case "all":
+		info.Name = "Contacts"
+	}
+
+	var photos []FlickrPhoto
+	var err error
+	if cfg.Count <= maxContactsPhotos && (contacts == "ff" || contacts == "all") {
+		client.logger.Debug("Fetching contacts' photos", "contacts", contacts)
+		photos, err = client.GetContactsPhotos(cfg.Count, ContactsPhotosOptions{
+			JustFriends: contacts == "ff",
+			SinglePhoto: cfg.FFSinglePhoto,
+			IncludeSelf: cfg.FFIncludeSelf,
+		})
+	} else {
+		photos, err = fetchPhotosByContact(client, cfg, contacts, ds)
+	}
+	if err != nil {
+		return nil, WrapFlickrAPI(err, fmt.Sprintf("failed to fetch %s photos", strings.ToLower(info.Name)))
+	}
+
+	client.logger.Info("Fetched contacts' photos", "contacts", contacts, "photos", len(photos))
+
+	return &feedSource{
+		info:   info,
+		photos: photos,
+	}, nil
+}
+
+// fetchPhotosByContact builds a friends & family photo list by fetching the
+// photostream of each matching contact and merging them newest-first.
+func fetchPhotosByContact(client *FlickrClient, cfg FeedConfig, contacts string, ds DateSource) ([]FlickrPhoto, error) {
+	client.logger.Debug("Listing contacts")
+	allContacts, err := client.GetContacts()
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := filterContacts(allContacts, contacts)
+
+	if cfg.FFIncludeSelf {
+		selfID, _, err := client.TestLogin()
+		if err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, selfID)
+	}
+
+	perContact := cfg.Count
+	if cfg.FFSinglePhoto {
+		perContact = 1
+	}
+
+	client.logger.Info("Fetching photos from each contact", "contacts", len(userIDs), "per_contact", perContact)
+
+	results := make([][]FlickrPhoto, len(userIDs))
+	errs := make([]error, len(userIDs))
+	forEachConcurrently(len(userIDs), contactConcurrency, func(i int) {
+		results[i], errs[i] = client.GetUserPhotos(userIDs[i], perContact)
+		if errs[i] != nil {
+			client.logger.Warn("Failed to fetch photos for contact", "user_id", userIDs[i], "error", errs[i])
+		}
+	})
+	if err := contactFetchError(errs); err != nil {
+		return nil, err
+	}
+
+	var photos []FlickrPhoto
+	for _, result := range results {
+		photos = append(photos, result...)
+	}
+	sortPhotosByDate(photos, ds)
+	if len(photos) > cfg.Count {
+		photos = photos[:cfg.Count]
+	}
+
+	return photos, nil
+}
+
+// contactFetchError decides whether failures fetching contacts' photostreams fail the
+// whole feed, returning the error to fail it with, or nil. Contacts that fail are
+// skipped only if others succeed and none failed authentication, so that an outage or
+// revoked token doesn't replace the feed with an empty or partial one.
+func contactFetchError(errs []error) error {
+	var firstErr error
+	failed := 0
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, ErrFlickrAuth) {
+			return err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		failed++
+	}
+	if failed > 0 && failed == len(errs) {
+		return fmt.Errorf("failed to fetch photos for all %d contacts: %w", failed, firstErr)
+	}
+	return nil
+}
+
+// filterContacts returns the NSIDs of the contacts matching a --ff-contacts value:
+// "ff" (friends and family), "friends", "family", or "all".
+func filterContacts(contacts []FlickrContact, which string) []string {
+	var userIDs []string
+	for _, contact := range contacts {
+		var match bool
+		switch which {
+		case "ff":
+			match = contact.Friend == 1 || contact.Family == 1
+		case "friends":
+			match = contact.Friend == 1
+		case "family":
+			match = contact.Family == 1
+		case "all":
+			match = true
+		}
+		if match {
+			userIDs = append(userIDs, contact.NSID)
+		}
+	}
+	return userIDs
+}
+
+// GenerateFeed builds the feed, writes it, and records metrics about it. If the output
+// file changed, it notifies the feed's WebSub hub, and if it gained new photos, it runs
+// the feed's change notifications. If those fail, it returns a *NotifyError along with
+// the result of writing the feed.
+func GenerateFeed(creds *Credentials, cfg FeedConfig) (WriteResult, error) {
+	start := time.Now()
+	var photos int
+	var feed *RSSFeed
+	var result WriteResult
+	err := func() error {
+		var err error
+		feed, err = BuildFeed(creds, cfg)
+		if err != nil {
+			return err
+		}
+		photos = len(feed.Items)
+		result, err = WriteFeed(feed, cfg)
+		if err != nil {
+			return err
+		}
+		if cfg.HTML != "" {
+			if err := WriteGallery(feed, cfg); err != nil {
+				return err
+			}
+		}
+		if cfg.CommentsFeed != "" {
+			commentsCfg := cfg
+			commentsCfg.Output = cfg.CommentsFeed
+			if _, err := WriteFeed(BuildCommentsFeed(feed), commentsCfg); err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+	metrics.ObserveFeed(cfg.FeedName(), time.Since(start), photos, err)
+	if err != nil {
+		return result, err
+	}
+
+	var notifyErr error
+	if result.Changed && cfg.WebSubHub != "" && cfg.Output != "" {
+		logger := cfg.Logger()
+		logger.Info("Notifying WebSub hub", "hub", cfg.WebSubHub, "topic", cfg.SelfURL)
+		if err := PublishToHub(cfg.WebSubHub, cfg.SelfURL); err != nil {
+			logger.Error("WebSub publish failed", "hub", cfg.WebSubHub, "error", err)
+			notifyErr = err
+		}
+	}
+	if result.Changed && len(result.NewItems) > 0 {
+		if err := NotifyChange(cfg, buildChangeNotification(feed, cfg, result.NewItems)); err != nil && notifyErr == nil {
+			notifyErr = err
+		}
+	}
+	if notifyErr != nil {
+		return result, &NotifyError{Err: notifyErr}
+	}
+	return result, nil
+}
+
+// WriteResult describes the outcome of WriteFeed.
+type WriteResult struct {
+	// Changed reports whether the output was written.
+	Changed bool
+	// NewItems holds the indexes of the feed's items that weren't in the previous
+	// output file; if there was no previous file, all items are new.
+	NewItems []int
+}
+
+// WriteFeed writes the feed to the feed's output file, or to stdout if it has none.
+// An output file whose recorded content hash matches the feed's isn't rewritten, so
+// its lastBuildDate and modification time only change when its content does; feeds
+// written to stdout are always reported as changed.
+//
+// The file is replaced atomically, so a failure leaves the previous feed in place.
+// Private feeds are written with permissions allowing only the current user to read
+// them, unless the feed is tokenized, in which case the secret token in the filename
+// protects it and the file is left readable by a web server.
+func WriteFeed(feed *RSSFeed, cfg FeedConfig) (WriteResult, error) {
+	output := cfg.Output
+	if output == "" {
+		if err := feed.WriteXML(os.Stdout); err != nil {
+			return WriteResult{}, WrapFileIO(err, "failed to write RSS feed")
+		}
+		return WriteResult{Changed: true}, nil
+	}
+
+	logger := cfg.Logger()
+
+	restrict := feed.Private && !cfg.Tokenize
+	if restrict {
+		if err := checkPrivateOutput(output); err != nil {
+			return WriteResult{}, err
+		}
+	}
+
+	hash, err := feed.ContentHash()
+	if err != nil {
+		return WriteResult{}, WrapFileIO(err, "failed to render RSS feed")
+	}
+	if hash == readFeedContentHash(output) {
+		logger.Info("RSS feed is unchanged; not rewriting it", "path", output)
+		return WriteResult{}, nil
+	}
+
+	previous := readFeedGUIDs(output)
+	result := WriteResult{
+		Changed:  true,
+		NewItems: newItemIndexes(feed, previous),
+	}
+
+	perm := os.FileMode(0644)
+	if restrict {
+		perm = 0600
+	}
+
+	logger.Info("Writing RSS feed", "path", output, "new_items", len(result.NewItems))
+	err = writeFileAtomic(output, perm, func(w io.Writer) error {
+		if err := feed.WriteXML(w); err != nil {
+			return WrapFileIO(err, fmt.Sprintf("failed to write RSS feed to %s", output))
+		}
+		return nil
+	})
+	if err != nil {
+		return WriteResult{}, err
+	}
+	return result, nil
+}
+
+func containsNonNumeric(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return true
+		}
+	}
+	return false
+}
+
+func isFlickrProfileURL(urlStr string) bool {
+	re := regexp.MustCompile(`^https?://(?:www\.)?flickr\.com/photos/[^/]+/?`)
+	return re.MatchString(urlStr)
+}
+
+func isFlickrGroupURL(urlStr string) bool {
+	re := regexp.MustCompile(`^https?://(?:www\.)?flickr\.com/groups/[^/]+/?`)
+	return re.MatchString(urlStr)
+}
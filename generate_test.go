@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContactFetchError(t *testing.T) {
+	authErr := ClassifyFlickrError(200, 98, "Invalid auth token")
+	serverErr := ClassifyFlickrError(502, 0, "API request failed with status 502")
+
+	tests := []struct {
+		name     string
+		errs     []error
+		wantErr  error
+		wantFail bool
+	}{
+		{"no contacts", nil, nil, false},
+		{"all succeed", []error{nil, nil}, nil, false},
+		{"some fail", []error{nil, serverErr, nil}, nil, false},
+		{"all fail", []error{serverErr, serverErr}, ErrFlickrServer, true},
+		{"one fails authentication", []error{nil, authErr, nil}, ErrFlickrAuth, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := contactFetchError(tt.errs)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("contactFetchError = %v, want failure: %v", err, tt.wantFail)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("contactFetchError = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildFeedMirrorRequiresTokenize(t *testing.T) {
+	tests := []struct {
+		name     string
+		photos   []map[string]any
+		tokenize bool
+		wantErr  bool
+	}{
+		{"public photos", []map[string]any{fakePhoto("1", true)}, false, false},
+		{"non-public photo", []map[string]any{fakePhoto("1", true), fakePhoto("2", false)}, false, true},
+		{"non-public photo, tokenized", []map[string]any{fakePhoto("1", true), fakePhoto("2", false)}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFakeUserFeed(t, tt.photos...)
+			mirrorDir := filepath.Join(t.TempDir(), "images")
+
+			feed, err := BuildFeed(testOAuthCreds, FeedConfig{
+				Users:         []string{"alice"},
+				Count:         10,
+				Tokenize:      tt.tokenize,
+				MirrorDir:     mirrorDir,
+				MirrorBaseURL: "https://example.com/images/",
+			})
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("BuildFeed: %v", err)
+				}
+				if len(feed.Items) != len(tt.photos) {
+					t.Errorf("feed has %d items, want %d", len(feed.Items), len(tt.photos))
+				}
+				return
+			}
+			if !errors.Is(err, ErrUsage) || !strings.Contains(err.Error(), "--tokenize") {
+				t.Errorf("got error %v (class %q), want a usage error suggesting --tokenize", err, ErrorClass(err))
+			}
+			if _, err := os.Stat(mirrorDir); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("mirror directory was created for a refused feed (stat error %v)", err)
+			}
+		})
+	}
+}
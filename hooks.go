@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// hookTimeout bounds how long an --on-change command or webhook request may take.
+const hookTimeout = 30 * time.Second
+
+// ChangeNotification is the JSON payload sent to --on-change commands and webhooks
+// when a feed is written with new photos.
+type ChangeNotification struct {
+	Feed      string         `json:"feed"`
+	Output    string         `json:"output"`
+	SelfURL   string         `json:"self_url,omitempty"`
+	NewPhotos []ChangedPhoto `json:"new_photos"`
+	Timestamp time.Time      `json:"timestamp"`
+}
+
+// ChangedPhoto describes a new photo in a ChangeNotification.
+type ChangedPhoto struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Owner     string    `json:"owner"`
+	OwnerName string    `json:"owner_name"`
+	URL       string    `json:"url"`
+	ImageURL  string    `json:"image_url,omitempty"`
+	VideoURL  string    `json:"video_url,omitempty"`
+	Date      time.Time `json:"date"`
+}
+
+// NotifyError is returned by GenerateFeed when the feed was written, but notifying the
+// WebSub hub, --on-change command, or webhook of the change failed.
+type NotifyError struct {
+	Err error
+}
+
+func (e *NotifyError) Error() string {
+	return fmt.Sprintf("feed was written, but change notification failed: %s", e.Err)
+}
+
+func (e *NotifyError) Unwrap() error {
+	return e.Err
+}
+
+// readFeedGUIDs returns the GUIDs of the items in an existing feed file, or nil if
+// the file doesn't exist or can't be parsed.
+func readFeedGUIDs(path string) map[string]bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var doc struct {
+		Items []struct {
+			GUID string `xml:"guid"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.NewDecoder(file).Decode(&doc); err != nil {
+		return nil
+	}
+
+	guids := make(map[string]bool, len(doc.Items))
+	for _, item := range doc.Items {
+		guids[item.GUID] = true
+	}
+	return guids
+}
+
+// newItemIndexes returns the indexes of the feed's items whose GUIDs aren't in previous.
+func newItemIndexes(feed *RSSFeed, previous map[string]bool) []int {
+	var indexes []int
+	for i, item := range feed.Items {
+		if !previous[item.GUID] {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// buildChangeNotification describes the given new items of a feed.
+func buildChangeNotification(feed *RSSFeed, cfg FeedConfig, indexes []int) ChangeNotification {
+	n := ChangeNotification{
+		Feed:      cfg.FeedName(),
+		Output:    cfg.Output,
+		SelfURL:   cfg.SelfURL,
+		NewPhotos: make([]ChangedPhoto, 0, len(indexes)),
+		Timestamp: time.Now().UTC(),
+	}
+	for _, i := range indexes {
+		item := feed.Items[i]
+		photo := feed.Photos[i]
+		changed := ChangedPhoto{
+			ID:        photo.ID,
+			Title:     photo.Title,
+			Owner:     photo.Owner,
+			OwnerName: photo.OwnerDisplayName(),
+			URL:       item.Link,
+			ImageURL:  firstNonEmpty(photo.URLLarge, photo.URL),
+		}
+		if photo.Video != nil {
+			changed.VideoURL = photo.Video.URL
+		}
+		if date, err := time.Parse(time.RFC1123Z, item.PubDate); err == nil {
+			changed.Date = date.UTC()
+		}
+		n.NewPhotos = append(n.NewPhotos, changed)
+	}
+	return n
+}
+
+// NotifyChange runs the feed's --on-change command and posts to its webhook, if
+// configured, with a JSON ChangeNotification. Both are attempted even if the other
+// fails; the first failure is returned.
+func NotifyChange(cfg FeedConfig, n ChangeNotification) error {
+	if cfg.OnChange == "" && cfg.Webhook == "" {
+		return nil
+	}
+
+	payload, err := json.MarshalIndent(n, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode change notification: %w", err)
+	}
+
+	logger := cfg.Logger()
+	var firstErr error
+
+	if cfg.OnChange != "" {
+		logger.Info("Running on-change command", "command", cfg.OnChange, "new_photos", len(n.NewPhotos))
+		if err := runChangeCommand(cfg.OnChange, n, payload); err != nil {
+			logger.Error("On-change command failed", "command", cfg.OnChange, "error", err)
+			firstErr = err
+		}
+	}
+
+	if cfg.Webhook != "" {
+		logger.Info("Posting webhook", "url", cfg.Webhook, "new_photos", len(n.NewPhotos))
+		if err := postWebhook(cfg.Webhook, payload); err != nil {
+			logger.Error("Webhook failed", "url", cfg.Webhook, "error", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
+
+// runChangeCommand runs command with the shell, passing the JSON payload on stdin and
+// a summary in environment variables. Its output is passed through to stderr.
+func runChangeCommand(command string, n ChangeNotification, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"FLICKR_RSS_FEED="+n.Feed,
+		"FLICKR_RSS_OUTPUT="+n.Output,
+		"FLICKR_RSS_SELF_URL="+n.SelfURL,
+		fmt.Sprintf("FLICKR_RSS_NEW_PHOTOS=%d", len(n.NewPhotos)),
+	)
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("on-change command failed: %w", err)
+	}
+	return nil
+}
+
+// postWebhook POSTs the JSON payload to url, treating any non-2xx response as a failure.
+func postWebhook(url string, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL '%s': %w", url, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "flickr-rss/"+version)
+
+	client := &http.Client{Timeout: hookTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
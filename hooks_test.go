@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testChangeNotification() ChangeNotification {
+	return ChangeNotification{
+		Feed:    "alice",
+		Output:  "/var/www/feeds/alice.xml",
+		SelfURL: "https://example.com/feeds/alice.xml",
+		NewPhotos: []ChangedPhoto{{
+			ID:        "12345",
+			Title:     "Sunset",
+			Owner:     "99999@N00",
+			OwnerName: "Alice",
+			URL:       "https://www.flickr.com/photos/99999@N00/12345/",
+			ImageURL:  "https://live.staticflickr.com/1/12345_abc_b.jpg",
+			Date:      time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		}},
+		Timestamp: time.Date(2024, 3, 2, 8, 30, 0, 0, time.UTC),
+	}
+}
+
+func TestNotifyChangeWebhook(t *testing.T) {
+	var gotMethod, gotContentType string
+	var got ChangeNotification
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotContentType = r.Header.Get("Content-Type")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding webhook payload: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	want := testChangeNotification()
+	if err := NotifyChange(FeedConfig{Output: want.Output, Webhook: srv.URL}, want); err != nil {
+		t.Fatalf("NotifyChange: %v", err)
+	}
+
+	if gotMethod != http.MethodPost {
+		t.Errorf("method = %s, want POST", gotMethod)
+	}
+	if gotContentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", gotContentType)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("payload = %+v, want %+v", got, want)
+	}
+}
+
+func TestNotifyChangeWebhookFailure(t *testing.T) {
+	for _, status := range []int{http.StatusMovedPermanently, http.StatusNotFound, http.StatusInternalServerError} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+				w.WriteHeader(status)
+			}))
+			defer srv.Close()
+
+			if err := postWebhook(srv.URL, []byte(`{}`)); err == nil {
+				t.Errorf("postWebhook succeeded for status %d, want an error", status)
+			}
+		})
+	}
+}
+
+func TestNotifyChangeRunsCommandDespiteWebhookFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	stdinFile := filepath.Join(t.TempDir(), "stdin.json")
+	cfg := FeedConfig{
+		Output:   "/var/www/feeds/alice.xml",
+		OnChange: `cat > "$STDIN_FILE"; test "$FLICKR_RSS_NEW_PHOTOS" = 1`,
+		Webhook:  srv.URL,
+	}
+	t.Setenv("STDIN_FILE", stdinFile)
+
+	want := testChangeNotification()
+	if err := NotifyChange(cfg, want); err == nil {
+		t.Error("NotifyChange succeeded despite the webhook failing")
+	}
+
+	data, err := os.ReadFile(stdinFile)
+	if err != nil {
+		t.Fatalf("on-change command didn't run: %v", err)
+	}
+	var got ChangeNotification
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("decoding on-change payload: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("payload = %+v, want %+v", got, want)
+	}
+}
+
+func TestNotifyErrorUnwraps(t *testing.T) {
+	inner := errors.New("webhook returned status 502")
+	err := error(&NotifyError{Err: inner})
+
+	var notifyErr *NotifyError
+	if !errors.As(err, &notifyErr) || !errors.Is(err, inner) {
+		t.Errorf("%v should be a *NotifyError wrapping %v", err, inner)
+	}
+}
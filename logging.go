@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+var (
+	logLevel  string
+	logFormat string
+)
+
+// setupLogging configures the default slog logger from the --log-level, --log-format,
+// and --verbose flags. Logs are written to stderr, leaving stdout for feed output.
+// Without --log-level, only warnings and errors are logged, or informational
+// messages too with --verbose.
+func setupLogging() error {
+	level := slog.LevelWarn
+	if verbose {
+		level = slog.LevelInfo
+	}
+	if logLevel != "" {
+		if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+			return NewUsage(fmt.Sprintf("invalid log level '%s' (must be one of: debug, info, warn, error)", logLevel))
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(logFormat) {
+	case "", "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return NewUsage(fmt.Sprintf("invalid log format '%s' (must be text or json)", logFormat))
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
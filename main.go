@@ -3,9 +3,9 @@ package main
 import (
 	"errors"
 	"fmt"
-	"io"
+	"log/slog"
+	"net/mail"
 	"os"
-	"regexp"
 
 	ec "github.com/cdzombak/exitcode_go"
 	"github.com/spf13/cobra"
@@ -17,13 +17,20 @@ var (
 		Short: "Generate RSS feeds for Flickr user photos",
 		Long: `flickr-rss generates RSS feeds containing the latest photos from a Flickr user.
 Each feed item includes an embedded image and RSS enclosure for the photo.`,
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			return setupLogging()
+		},
+		SilenceErrors: true,
 	}
 
 	generateCmd = &cobra.Command{
-		Use:   "generate [username|userid|profile_url]",
-		Short: "Generate RSS feed for a Flickr user or friends & family",
-		Args:  cobra.MaximumNArgs(1),
-		RunE:  runGenerate,
+		Use:   "generate [username|userid|profile_url ...]",
+		Short: "Generate RSS feed for Flickr users, groups, or friends & family",
+		Long: `Generate an RSS feed from one or more sources: Flickr users (given as arguments),
+group pools (--group), and your friends & family (--ff). When multiple sources are
+given, their photos are merged into a single feed.`,
+		Args: cobra.ArbitraryArgs,
+		RunE: runGenerate,
 	}
 
 	authCmd = &cobra.Command{
@@ -32,6 +39,54 @@ Each feed item includes an embedded image and RSS enclosure for the photo.`,
 		RunE:  runAuth,
 	}
 
+	doctorCmd = &cobra.Command{
+		Use:   "doctor",
+		Short: "Check credentials, Flickr API access, clock, and output path",
+		Long: `Run a series of health checks: that credentials are present and valid, that Flickr
+accepts the API key and (if present) the OAuth token, that the local clock agrees with
+Flickr's, and that the output path (--output) is writable. Each check's result is printed,
+and the exit status reflects the first failed check.`,
+		Args:         cobra.NoArgs,
+		RunE:         runDoctor,
+		SilenceUsage: true,
+	}
+
+	daemonCmd = &cobra.Command{
+		Use:   "daemon",
+		Short: "Regenerate configured feeds on a schedule",
+		Long: `Run continuously, regenerating each feed in a YAML configuration file on its own
+interval. Feeds that fail are retried with backoff; feeds that fail due to authentication
+or configuration problems are paused until the configuration is reloaded with SIGHUP.`,
+		Args:         cobra.NoArgs,
+		RunE:         runDaemon,
+		SilenceUsage: true,
+	}
+
+	emailDigestCmd = &cobra.Command{
+		Use:   "email-digest [username|userid|profile_url ...]",
+		Short: "Email a digest of new photos from Flickr users, groups, or friends & family",
+		Long: `Fetch photos from the same sources as generate, and email an HTML digest, with inline
+thumbnails, of those that weren't in the previous digest. Photos already sent are recorded
+in a state file (--state). The digest is sent over SMTP, or written to an .eml file with --eml
+for testing. If there are no new photos, nothing is sent.`,
+		Args:         cobra.ArbitraryArgs,
+		RunE:         runEmailDigest,
+		SilenceUsage: true,
+	}
+
+	exportCmd = &cobra.Command{
+		Use:   "export [username|userid|profile_url ...]",
+		Short: "Export complete photostreams or albums to a JSON Lines manifest",
+		Long: `Page through the entire photostream of each given user, each of your contacts' photostreams
+(--ff), and each album (--album), appending the complete metadata of every photo not already
+exported to a JSON Lines manifest (--manifest), and optionally downloading each photo's original
+(--originals). An interrupted export resumes from a checkpoint file next to the manifest; run it
+again later to add new photos.`,
+		Args:         cobra.ArbitraryArgs,
+		RunE:         runExport,
+		SilenceUsage: true,
+	}
+
 	versionCmd = &cobra.Command{
 		Use:   "version",
 		Short: "Print version information and exit",
@@ -39,16 +94,25 @@ Each feed item includes an embedded image and RSS enclosure for the photo.`,
 	}
 
 	// Global flags
-	apiKey        string
-	apiSecret     string
-	oauthToken    string
-	oauthSecret   string
-	credsFile     string
-	output        string
-	verbose       bool
-	saveCreds     string
-	friendsFamily bool
-	photoCount    int
+	apiKey          string
+	apiSecret       string
+	oauthToken      string
+	oauthSecret     string
+	credsFile       string
+	feedTokenSecret string
+	output          string
+	verbose         bool
+	saveCreds       string
+	feedFlags       FeedConfig
+	filtersFile     string
+
+	metricsTextfile  string
+	daemonConfigFile string
+	printStatus      bool
+	digestFlags      DigestConfig
+	exportFlags      ExportConfig
+
+	generateFeedTokenSecret bool
 
 	// injected at build time:
 	version string = "<dev>"
@@ -57,6 +121,10 @@ Each feed item includes an embedded image and RSS enclosure for the photo.`,
 func init() {
 	rootCmd.AddCommand(generateCmd)
 	rootCmd.AddCommand(authCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(daemonCmd)
+	rootCmd.AddCommand(emailDigestCmd)
+	rootCmd.AddCommand(exportCmd)
 	rootCmd.AddCommand(versionCmd)
 
 	// Global persistent flags
@@ -64,44 +132,86 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&apiSecret, "api-secret", "", "Flickr API secret")
 	rootCmd.PersistentFlags().StringVar(&oauthToken, "oauth-token", "", "OAuth token")
 	rootCmd.PersistentFlags().StringVar(&oauthSecret, "oauth-token-secret", "", "OAuth token secret")
+	rootCmd.PersistentFlags().StringVar(&feedTokenSecret, "feed-token-secret", "", "Secret from which tokenized feeds' tokens are derived (default: from the credentials file)")
 	rootCmd.PersistentFlags().StringVarP(&credsFile, "creds-file", "c", "", "Path to credentials YAML file")
 	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "", "Output file for RSS feed (default: stdout)")
-	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output (same as --log-level info)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "", "Minimum level of log messages: debug, info, warn, or error (default: warn)")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Log format: text or json")
 
 	// Auth command specific flags
 	authCmd.Flags().StringVar(&saveCreds, "save-creds", "", "Save credentials to specified YAML file")
+	authCmd.Flags().BoolVar(&generateFeedTokenSecret, "generate-feed-token-secret", false, "Print a new feed token secret for --tokenize, to add to the credentials file, without authenticating")
+
+	// Daemon command specific flags
+	daemonCmd.Flags().StringVar(&daemonConfigFile, "config", "", "Path to YAML file of feeds to generate")
 
 	// Generate command specific flags
-	generateCmd.Flags().BoolVar(&friendsFamily, "ff", false, "Generate feed from friends & family photos (requires OAuth)")
-	generateCmd.Flags().IntVar(&photoCount, "count", 20, "Number of photos to include in the feed")
+	addSourceFlags(generateCmd)
+	generateCmd.Flags().StringVar(&feedFlags.SelfURL, "self-url", "", "URL where the generated feed will be published, used for the feed's self link")
+	generateCmd.Flags().BoolVar(&feedFlags.Comments, "comments", false, "Append each photo's most recent comments to its item (makes one extra API request per photo)")
+	generateCmd.Flags().StringVar(&feedFlags.CommentsFeed, "comments-feed", "", "Also write a feed of the comments on the feed's photos to this file (makes one extra API request per photo)")
+	generateCmd.Flags().StringVar(&feedFlags.HTML, "html", "", "Also write a static HTML gallery page of the feed's photos to this file")
+	generateCmd.Flags().StringVar(&feedFlags.MirrorDir, "mirror-dir", "", "Download the feed's images to this directory, and link to them there instead of on Flickr (requires --mirror-base-url)")
+	generateCmd.Flags().StringVar(&feedFlags.MirrorBaseURL, "mirror-base-url", "", "URL at which the --mirror-dir directory is published")
+	generateCmd.Flags().StringVar(&feedFlags.WebSubHub, "websub-hub", "", "WebSub hub to advertise in the feed and notify when the feed file changes (requires --self-url)")
+	generateCmd.Flags().BoolVar(&feedFlags.Tokenize, "tokenize", false, "Add a secret token to the output filename and self URL, so the feed can only be found by those given its URL")
+	generateCmd.Flags().StringVar(&feedFlags.OnChange, "on-change", "", "Shell command to run when the feed is written with new photos; it receives a JSON description of them on stdin")
+	generateCmd.Flags().StringVar(&feedFlags.Webhook, "webhook", "", "URL to POST a JSON description of new photos to when the feed is written with them")
+	generateCmd.Flags().BoolVar(&printStatus, "print-status", false, "After writing the feed, print \"changed\" or \"unchanged\" to stdout")
+	generateCmd.Flags().StringVar(&metricsTextfile, "metrics-textfile", "", "After generating the feed, write Prometheus metrics to this file for node_exporter's textfile collector")
+
+	// Export command specific flags
+	exportCmd.Flags().BoolVar(&exportFlags.FriendsFamily, "ff", false, "Export the photostream of each of your friends & family (requires OAuth)")
+	exportCmd.Flags().StringVar(&exportFlags.FFContacts, "ff-contacts", "ff", "Whose photostreams to export with --ff: ff (friends and family), friends, family, or all contacts")
+	exportCmd.Flags().StringSliceVar(&exportFlags.Albums, "album", nil, "Export this album (album ID or URL); may be repeated")
+	exportCmd.Flags().StringVar(&exportFlags.Manifest, "manifest", "", "Path to JSON Lines file to append exported photos to (required)")
+	exportCmd.Flags().StringVar(&exportFlags.Originals, "originals", "", "Download each photo's original file to this directory")
+
+	// Email digest command specific flags
+	addSourceFlags(emailDigestCmd)
+	emailDigestCmd.Flags().StringVar(&digestFlags.State, "state", "", "Path to JSON file recording the photos already sent (required)")
+	emailDigestCmd.Flags().StringVar(&digestFlags.Subject, "subject", "", "Email subject (default: the feed title and number of new photos)")
+	emailDigestCmd.Flags().StringVar(&digestFlags.From, "from", "", "Sender email address")
+	emailDigestCmd.Flags().StringSliceVar(&digestFlags.To, "to", nil, "Recipient email address; may be repeated")
+	emailDigestCmd.Flags().StringVar(&digestFlags.SMTPHost, "smtp-host", "", "SMTP server to send the digest through")
+	emailDigestCmd.Flags().IntVar(&digestFlags.SMTPPort, "smtp-port", 587, "SMTP server port; 465 uses implicit TLS, others use STARTTLS when available")
+	emailDigestCmd.Flags().StringVar(&digestFlags.SMTPUser, "smtp-user", "", "SMTP username; the password is read from $FLICKR_RSS_SMTP_PASSWORD")
+	emailDigestCmd.Flags().StringVar(&digestFlags.EML, "eml", "", "Write the digest to this .eml file instead of sending it")
+	emailDigestCmd.Flags().BoolVar(&digestFlags.AllowPrivate, "allow-private", false, "Allow emailing a digest that can include non-public photos, such as from --me or --ff")
 }
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-
-		// Set exit code based on error type
-		var exitCode int
-		switch {
-		case errors.Is(err, ErrFlickrAuth):
-			exitCode = ec.NoPermission
-		case errors.Is(err, ErrFlickrServer):
-			exitCode = ec.Unavailable
-		case errors.Is(err, ErrFlickrUsage):
-			exitCode = ec.Usage
-		case errors.Is(err, ErrFlickrAPI):
-			exitCode = ec.Failure
-		case errors.Is(err, ErrFileIO):
-			exitCode = ec.IOErr
-		case errors.Is(err, ErrInputs):
-			exitCode = ec.NotConfigured
-		case errors.Is(err, ErrUsage):
-			exitCode = ec.InvalidArgument
-		default:
-			exitCode = ec.Failure
+		if logFormat == "json" {
+			slog.Error("Command failed", "error_class", ErrorClass(err), "error", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		}
 
-		os.Exit(exitCode)
+		os.Exit(exitCodeForError(err))
+	}
+}
+
+// exitCodeForError returns the process exit code for err, based on its type.
+func exitCodeForError(err error) int {
+	switch {
+	case errors.Is(err, ErrFlickrAuth):
+		return ec.NoPermission
+	case errors.Is(err, ErrFlickrServer):
+		return ec.Unavailable
+	case errors.Is(err, ErrFlickrUsage):
+		return ec.Usage
+	case errors.Is(err, ErrInputs):
+		return ec.NotConfigured
+	case errors.Is(err, ErrFlickrAPI):
+		return ec.Failure
+	case errors.Is(err, ErrFileIO):
+		return ec.IOErr
+	case errors.Is(err, ErrUsage):
+		return ec.InvalidArgument
+	default:
+		return ec.Failure
 	}
 }
 
@@ -111,16 +221,11 @@ func runVersion(_ *cobra.Command, _ []string) error {
 }
 
 func runGenerate(cmd *cobra.Command, args []string) error {
-	// Handle friends & family mode
-	if friendsFamily {
-		return runGenerateFriendsFamily(cmd, args)
-	}
-
-	if len(args) == 0 {
-		return NewUsage("username, user ID, or profile URL is required unless using -ff flag")
+	cfg, err := feedConfigFromFlags(args)
+	if err != nil {
+		return err
 	}
-
-	userInput := args[0]
+	cfg.Output = output
 
 	// Load credentials
 	creds, err := loadCredsIfProvided()
@@ -128,101 +233,118 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		return WrapInputs(err, "failed to load credentials")
 	}
 
-	if err := creds.Validate(); err != nil {
-		return WrapInputs(err, "invalid credentials")
+	cfg, err = ApplyFeedToken(creds, cfg)
+	if err != nil {
+		return err
 	}
 
-	client := NewFlickrClient(creds)
-
-	// Determine if userInput is a profile URL, username, or user ID
-	var userID string
-	var displayName string
+	// Non-public photos from your own photostream may only be written to a local file,
+	// which WriteFeed creates with restrictive permissions
+	if cfg.Me && cfg.Filters.AllowsNonPublic() && cfg.Output == "" {
+		return NewUsage("a feed including your non-public photos must be written to a file with --output; use --privacy public to write public photos elsewhere")
+	}
+	if printStatus && cfg.Output == "" {
+		return NewUsage("--print-status requires --output")
+	}
+	if (cfg.OnChange != "" || cfg.Webhook != "") && cfg.Output == "" {
+		return NewUsage("--on-change and --webhook require --output")
+	}
 
-	if verbose {
-		fmt.Fprintf(os.Stderr, "Looking up user: %s\n", userInput)
+	if metricsTextfile != "" {
+		metrics.LoadTextfile(metricsTextfile)
 	}
 
-	// Check if userInput is a Flickr profile URL
-	if isFlickrProfileURL(userInput) {
-		if verbose {
-			fmt.Fprintf(os.Stderr, "Detected Flickr profile URL, looking up user\n")
-		}
-		userID, err = client.LookupUserByURL(userInput)
-		if err != nil {
-			return WrapFlickrAPI(err, fmt.Sprintf("failed to lookup user from URL '%s'", userInput))
-		}
-		// Get the actual username for display purposes
-		displayName, err = client.GetUserInfo(userID)
-		if err != nil {
-			if verbose {
-				fmt.Fprintf(os.Stderr, "Warning: failed to get username, using user ID: %v\n", err)
+	result, err := GenerateFeed(creds, cfg)
+
+	if metricsTextfile != "" {
+		if mErr := metrics.WriteTextfile(metricsTextfile); mErr != nil {
+			if err != nil {
+				// Report the generation failure, not the metrics failure
+				slog.Error("Failed to write metrics textfile", "path", metricsTextfile, "error", mErr)
+			} else {
+				err = mErr
 			}
-			displayName = userID
 		}
-	} else if containsNonNumeric(userInput) {
-		// Try to find user by username (if it contains non-numeric characters, likely a username)
-		userID, err = client.FindUserByUsername(userInput)
-		if err != nil {
-			return WrapFlickrAPI(err, fmt.Sprintf("failed to find user by username '%s'", userInput))
-		}
-		displayName = userInput
-	} else {
-		// Assume it's already a user ID
-		userID = userInput
-		displayName = userInput
 	}
 
-	if verbose {
-		fmt.Fprintf(os.Stderr, "Using user ID: %s\n", userID)
-		fmt.Fprintf(os.Stderr, "Display name: %s\n", displayName)
+	// A failed change notification still leaves the feed written, so report its status
+	var notifyErr *NotifyError
+	if printStatus && (err == nil || errors.As(err, &notifyErr)) {
+		if result.Changed {
+			fmt.Println("changed")
+		} else {
+			fmt.Println("unchanged")
+		}
 	}
 
-	// Fetch latest photos
-	photos, err := client.GetUserPhotos(userID, photoCount)
+	return err
+}
+
+func runEmailDigest(_ *cobra.Command, args []string) error {
+	cfg := digestFlags
+	feed, err := feedConfigFromFlags(args)
 	if err != nil {
-		return WrapFlickrAPI(err, fmt.Sprintf("failed to fetch photos for user %s", userID))
+		return err
 	}
+	cfg.Feed = feed
+	cfg.SMTPPassword = os.Getenv("FLICKR_RSS_SMTP_PASSWORD")
 
-	if verbose {
-		fmt.Fprintf(os.Stderr, "Found %d photos\n", len(photos))
+	if cfg.State == "" {
+		return NewUsage("--state is required")
 	}
-
-	// Generate RSS feed
-	feed := GenerateRSSFeed(photos, displayName)
-
-	// Output RSS feed
-	var writer io.Writer = os.Stdout
-	if output != "" {
-		file, err := os.Create(output)
-		if err != nil {
-			return WrapFileIO(err, fmt.Sprintf("failed to create output file %s", output))
+	if cfg.EML == "" && cfg.SMTPHost == "" {
+		return NewUsage("either --smtp-host or --eml is required")
+	}
+	if cfg.From == "" || len(cfg.To) == 0 {
+		return NewUsage("--from and --to are required")
+	}
+	for _, addr := range append([]string{cfg.From}, cfg.To...) {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return NewUsage(fmt.Sprintf("invalid email address '%s': %s", addr, err))
 		}
-		defer file.Close()
-		writer = file
+	}
 
-		if verbose {
-			fmt.Fprintf(os.Stderr, "Writing RSS feed to: %s\n", output)
-		}
+	creds, err := loadCredsIfProvided()
+	if err != nil {
+		return WrapInputs(err, "failed to load credentials")
 	}
 
-	return feed.WriteXML(writer)
+	_, err = SendDigest(creds, cfg)
+	return err
 }
 
-func containsNonNumeric(s string) bool {
-	for _, r := range s {
-		if r < '0' || r > '9' {
-			return true
-		}
+func runExport(_ *cobra.Command, args []string) error {
+	cfg := exportFlags
+	cfg.Users = args
+	if cfg.Manifest == "" {
+		return NewUsage("--manifest is required")
+	}
+
+	creds, err := loadCredsIfProvided()
+	if err != nil {
+		return WrapInputs(err, "failed to load credentials")
+	}
+	if err := creds.Validate(); err != nil {
+		return WrapInputs(err, "invalid credentials")
 	}
-	return false
-}
 
-func isFlickrProfileURL(urlStr string) bool {
-	re := regexp.MustCompile(`^https?://(?:www\.)?flickr\.com/photos/[^/]+/?`)
-	return re.MatchString(urlStr)
+	result, err := Export(creds, cfg)
+	if err == nil || result.Exported > 0 {
+		fmt.Printf("Exported %d new photos to %s (%d already exported)\n", result.Exported, cfg.Manifest, result.Skipped)
+	}
+	return err
 }
 
 func runAuth(cmd *cobra.Command, args []string) error {
+	if generateFeedTokenSecret {
+		secret, err := newFeedTokenSecret()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("feed_token_secret: %s\n", secret)
+		return nil
+	}
+
 	if apiKey == "" || apiSecret == "" {
 		return NewUsage("API key and secret are required for authentication. Use --api-key and --api-secret flags")
 	}
@@ -232,6 +354,9 @@ func runAuth(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return WrapFlickrAuth(err, "authentication failed")
 	}
+	if creds.FeedTokenSecret, err = newFeedTokenSecret(); err != nil {
+		return err
+	}
 
 	if saveCreds != "" {
 		if err := saveCredentials(creds, saveCreds); err != nil {
@@ -244,67 +369,53 @@ func runAuth(cmd *cobra.Command, args []string) error {
 		fmt.Printf("API Secret: %s\n", creds.APISecret)
 		fmt.Printf("OAuth Token: %s\n", creds.OAuthToken)
 		fmt.Printf("OAuth Token Secret: %s\n", creds.OAuthTokenSecret)
+		fmt.Printf("Feed Token Secret: %s\n", creds.FeedTokenSecret)
 	}
 
 	return nil
 }
 
-func runGenerateFriendsFamily(cmd *cobra.Command, args []string) error {
-	// Load credentials
-	creds, err := loadCredsIfProvided()
-	if err != nil {
-		return WrapInputs(err, "failed to load credentials")
-	}
-
-	if err := creds.Validate(); err != nil {
-		return WrapInputs(err, "invalid credentials")
-	}
-
-	// Verify OAuth credentials are present for friends & family access
-	if creds.OAuthToken == "" || creds.OAuthTokenSecret == "" {
-		return NewUsage("friends & family feed requires OAuth authentication. Run 'flickr-rss auth' first")
-	}
-
-	client := NewFlickrClient(creds)
-
-	if verbose {
-		fmt.Fprintf(os.Stderr, "Fetching friends & family photos...\n")
-	}
-
-	// Fetch latest photos from friends & family (max 50 due to API limits)
-	requestCount := photoCount
-	if requestCount > 50 {
-		if verbose {
-			fmt.Fprintf(os.Stderr, "Warning: Friends & family feed limited to 50 photos (requested %d)\n", photoCount)
-		}
-		requestCount = 50
-	}
-	photos, err := client.GetContactsPhotos(requestCount)
-	if err != nil {
-		return WrapFlickrAPI(err, "failed to fetch friends & family photos")
-	}
-
-	if verbose {
-		fmt.Fprintf(os.Stderr, "Found %d photos from friends & family\n", len(photos))
-	}
+// addSourceFlags adds flags selecting a feed's photo sources, and how photos are
+// filtered and presented, to a command that builds a feed.
+func addSourceFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.BoolVar(&feedFlags.Me, "me", false, "Include your own photostream, including non-public photos (requires OAuth)")
+	flags.StringSliceVar(&feedFlags.Filters.Privacy, "privacy", nil, "Only include photos with these privacy levels: public, friends, family, private")
+	flags.BoolVar(&feedFlags.FriendsFamily, "ff", false, "Include friends & family photos (requires OAuth)")
+	flags.StringVar(&feedFlags.FFContacts, "ff-contacts", "ff", "Whose photos to include with --ff: ff (friends and family), friends, family, or all contacts")
+	flags.BoolVar(&feedFlags.FFSinglePhoto, "ff-single-photo", false, "With --ff, include only the latest photo from each contact")
+	flags.BoolVar(&feedFlags.FFIncludeSelf, "ff-include-self", false, "With --ff, include your own photos too")
+	flags.StringSliceVar(&feedFlags.Groups, "group", nil, "Include photos from this group's pool (group NSID or URL); may be repeated")
+	flags.IntVar(&feedFlags.Count, "count", 20, "Number of photos to fetch from each source and include in the feed; filters apply to the fetched photos, so a filtered feed may have fewer")
+	flags.StringSliceVar(&feedFlags.Include, "include", nil, "Extra photo details to include in feed items: tags, geo, exif, license, views")
+	flags.StringVar(&filtersFile, "filters", "", "Path to YAML file of filter rules")
+	flags.StringSliceVar(&feedFlags.Filters.IncludeTags, "include-tag", nil, "Only include photos with at least one of these tags")
+	flags.StringSliceVar(&feedFlags.Filters.ExcludeTags, "exclude-tag", nil, "Exclude photos with any of these tags")
+	flags.StringVar(&feedFlags.Filters.TitleMatch, "title-match", "", "Only include photos whose titles match this regular expression")
+	flags.StringVar(&feedFlags.Filters.TitleExclude, "title-exclude", "", "Exclude photos whose titles match this regular expression")
+	flags.StringSliceVar(&feedFlags.Filters.Licenses, "license", nil, "Only include photos with one of these Flickr license IDs")
+	flags.StringVar(&feedFlags.Filters.Media, "media", "", "Only include photos or videos: photo or video")
+	flags.IntVar(&feedFlags.Filters.MinWidth, "min-width", 0, "Exclude photos narrower than this many pixels")
+	flags.IntVar(&feedFlags.Filters.MinHeight, "min-height", 0, "Exclude photos shorter than this many pixels")
+	flags.StringVar(&feedFlags.Filters.Since, "since", "", "Exclude photos dated before this date (YYYY-MM-DD or RFC 3339)")
+	flags.StringVar(&feedFlags.Filters.Until, "until", "", "Exclude photos dated after this date (YYYY-MM-DD or RFC 3339)")
+	flags.StringSliceVar(&feedFlags.Filters.Owners, "owner", nil, "Only include photos from these users (NSIDs or usernames)")
+	flags.StringSliceVar(&feedFlags.Filters.ExcludeOwners, "exclude-owner", nil, "Exclude photos from these users (NSIDs or usernames)")
+	flags.StringVar(&feedFlags.DateSource, "date-source", string(DateSourceUploaded), "Date used for item pubDate and ordering: uploaded, taken, or updated")
+}
 
-	// Generate RSS feed
-	feed := GenerateRSSFeed(photos, "Friends & Family")
+// feedConfigFromFlags returns the feed described by the source flags and the given
+// users, with any --filters file merged into its filters.
+func feedConfigFromFlags(users []string) (FeedConfig, error) {
+	cfg := feedFlags
+	cfg.Users = users
 
-	// Output RSS feed
-	var writer io.Writer = os.Stdout
-	if output != "" {
-		file, err := os.Create(output)
+	if filtersFile != "" {
+		fileRules, err := LoadFilterRules(filtersFile)
 		if err != nil {
-			return WrapFileIO(err, fmt.Sprintf("failed to create output file %s", output))
-		}
-		defer file.Close()
-		writer = file
-
-		if verbose {
-			fmt.Fprintf(os.Stderr, "Writing RSS feed to: %s\n", output)
+			return cfg, err
 		}
+		cfg.Filters = fileRules.Merge(cfg.Filters)
 	}
-
-	return feed.WriteXML(writer)
+	return cfg, nil
 }
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiLatencyBuckets are the upper bounds, in seconds, of the Flickr API request latency histogram.
+var apiLatencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Metrics collects Prometheus metrics about feed generation and Flickr API requests.
+// It's written in the Prometheus text exposition format, either served over HTTP by
+// long-running modes or written to a node_exporter textfile after a one-shot run.
+type Metrics struct {
+	mu sync.Mutex
+
+	feedDuration    map[string]float64
+	feedPhotos      map[string]float64
+	feedLastSuccess map[string]float64
+	feedRuns        map[[2]string]float64 // feed, result
+
+	apiRequests map[string]float64
+	apiErrors   map[[2]string]float64 // method, error class
+	apiLatency  map[string]*histogram
+}
+
+type histogram struct {
+	counts []float64 // cumulative counts, one per bucket in apiLatencyBuckets
+	count  float64
+	sum    float64
+}
+
+// metrics is the process-wide metrics registry.
+var metrics = NewMetrics()
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		feedDuration:    make(map[string]float64),
+		feedPhotos:      make(map[string]float64),
+		feedLastSuccess: make(map[string]float64),
+		feedRuns:        make(map[[2]string]float64),
+		apiRequests:     make(map[string]float64),
+		apiErrors:       make(map[[2]string]float64),
+		apiLatency:      make(map[string]*histogram),
+	}
+}
+
+// ObserveAPIRequest records a Flickr API request and, if it failed, its error class.
+func (m *Metrics) ObserveAPIRequest(method string, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.apiRequests[method]++
+	if err != nil {
+		m.apiErrors[[2]string{method, ErrorClass(err)}]++
+	}
+
+	h, ok := m.apiLatency[method]
+	if !ok {
+		h = &histogram{counts: make([]float64, len(apiLatencyBuckets))}
+		m.apiLatency[method] = h
+	}
+	seconds := duration.Seconds()
+	for i, bound := range apiLatencyBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// ObserveFeed records the outcome of generating a feed. Photo counts and the
+// last-success timestamp are only updated when generation succeeds.
+func (m *Metrics) ObserveFeed(feed string, duration time.Duration, photos int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.feedDuration[feed] = duration.Seconds()
+	result := "success"
+	if err != nil {
+		result = "failure"
+	} else {
+		m.feedPhotos[feed] = float64(photos)
+		m.feedLastSuccess[feed] = float64(time.Now().Unix())
+	}
+	m.feedRuns[[2]string{feed, result}]++
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	writeGauge := func(name, help string, values map[string]float64, label string) {
+		fmt.Fprintf(cw, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		for _, key := range sortedKeys(values) {
+			fmt.Fprintf(cw, "%s{%s} %s\n", name, labelPair(label, key), formatFloat(values[key]))
+		}
+	}
+	writeCounter2 := func(name, help string, values map[[2]string]float64, label1, label2 string) {
+		fmt.Fprintf(cw, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		keys := make([][2]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i][0] != keys[j][0] {
+				return keys[i][0] < keys[j][0]
+			}
+			return keys[i][1] < keys[j][1]
+		})
+		for _, key := range keys {
+			fmt.Fprintf(cw, "%s{%s,%s} %s\n", name, labelPair(label1, key[0]), labelPair(label2, key[1]), formatFloat(values[key]))
+		}
+	}
+
+	writeGauge("flickr_rss_feed_generation_duration_seconds", "Duration of the feed's most recent generation.", m.feedDuration, "feed")
+	writeGauge("flickr_rss_feed_photos", "Number of photos in the feed at its most recent successful generation.", m.feedPhotos, "feed")
+	writeGauge("flickr_rss_feed_last_success_timestamp_seconds", "Unix time of the feed's most recent successful generation.", m.feedLastSuccess, "feed")
+	writeCounter2("flickr_rss_feed_generations_total", "Feed generations, by result.", m.feedRuns, "feed", "result")
+
+	fmt.Fprintf(cw, "# HELP flickr_rss_api_requests_total Flickr API requests, by method.\n# TYPE flickr_rss_api_requests_total counter\n")
+	for _, method := range sortedKeys(m.apiRequests) {
+		fmt.Fprintf(cw, "flickr_rss_api_requests_total{%s} %s\n", labelPair("method", method), formatFloat(m.apiRequests[method]))
+	}
+	writeCounter2("flickr_rss_api_request_errors_total", "Failed Flickr API requests, by method and error class.", m.apiErrors, "method", "class")
+
+	name := "flickr_rss_api_request_duration_seconds"
+	fmt.Fprintf(cw, "# HELP %s Latency of Flickr API requests, by method.\n# TYPE %s histogram\n", name, name)
+	methods := make([]string, 0, len(m.apiLatency))
+	for method := range m.apiLatency {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		h := m.apiLatency[method]
+		label := labelPair("method", method)
+		for i, bound := range apiLatencyBuckets {
+			fmt.Fprintf(cw, "%s_bucket{%s,le=\"%s\"} %s\n", name, label, formatFloat(bound), formatFloat(h.counts[i]))
+		}
+		fmt.Fprintf(cw, "%s_bucket{%s,le=\"+Inf\"} %s\n", name, label, formatFloat(h.count))
+		fmt.Fprintf(cw, "%s_sum{%s} %s\n", name, label, formatFloat(h.sum))
+		fmt.Fprintf(cw, "%s_count{%s} %s\n", name, label, formatFloat(h.count))
+	}
+
+	if err := cw.w.Flush(); err != nil {
+		return cw.n, err
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP serves the metrics for a Prometheus /metrics endpoint.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTextfile writes the metrics to the given file for node_exporter's textfile
+// collector. The file is replaced atomically so the collector never reads a partial file.
+func (m *Metrics) WriteTextfile(path string) error {
+	return writeFileAtomic(path, 0644, func(w io.Writer) error {
+		if _, err := m.WriteTo(w); err != nil {
+			return WrapFileIO(err, fmt.Sprintf("failed to write metrics file %s", path))
+		}
+		return nil
+	})
+}
+
+// LoadTextfile restores the per-feed gauges that only change on success (photo counts
+// and last-success timestamps) from a textfile written by a previous run, so a failed
+// one-shot run doesn't lose them. A missing or unreadable file is ignored.
+func (m *Metrics) LoadTextfile(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, line := range strings.Split(string(data), "\n") {
+		var values map[string]float64
+		switch {
+		case strings.HasPrefix(line, "flickr_rss_feed_photos{"):
+			values = m.feedPhotos
+		case strings.HasPrefix(line, "flickr_rss_feed_last_success_timestamp_seconds{"):
+			values = m.feedLastSuccess
+		default:
+			continue
+		}
+
+		// Lines are of the form: name{feed="..."} value
+		labelStart := strings.Index(line, `{feed="`)
+		labelEnd := strings.LastIndex(line, `"} `)
+		if labelStart < 0 || labelEnd < labelStart {
+			continue
+		}
+		feed, err := strconv.Unquote(line[labelStart+len(`{feed=`) : labelEnd+1])
+		if err != nil {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(line[labelEnd+len(`"} `):]), 64)
+		if err != nil {
+			continue
+		}
+		values[feed] = value
+	}
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labelPair(name, value string) string {
+	return fmt.Sprintf(`%s="%s"`, name, labelValueEscaper.Replace(value))
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
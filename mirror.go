@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// mirrorConcurrency bounds the number of images downloaded at once.
+	mirrorConcurrency = 4
+	// mirrorTimeout bounds how long downloading each image may take.
+	mirrorTimeout = 2 * time.Minute
+)
+
+// flickrImageName matches the filenames of Flickr's static image URLs, which are made
+// of the photo's ID, its secret, and a size suffix, and never change for a given image.
+var flickrImageName = regexp.MustCompile(`^[0-9]+_[0-9a-f]+(_[a-z0-9]+)?\.(jpg|png|gif)$`)
+
+// mirrorPhotoImages downloads the image each photo's feed item embeds and encloses
+// into dir, and rewrites the photo's image URL to the copy under baseURL. Images that
+// have already been downloaded aren't fetched again, so an interrupted run resumes
+// where it left off. Photos whose images can't be downloaded keep their Flickr URLs.
+func mirrorPhotoImages(photos []FlickrPhoto, dir, baseURL string, logger *slog.Logger) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return WrapFileIO(err, fmt.Sprintf("failed to create mirror directory %s", dir))
+	}
+
+	client := &http.Client{Timeout: mirrorTimeout}
+	forEachConcurrently(len(photos), mirrorConcurrency, func(i int) {
+		photo := &photos[i]
+		// The same image GenerateRSSFeed embeds and uses as the enclosure
+		field := &photo.URLLarge
+		if *field == "" {
+			field = &photo.URL
+		}
+		if *field == "" {
+			return
+		}
+
+		name := mirrorFileName(*photo, *field)
+		if err := downloadFile(client, *field, filepath.Join(dir, name), 0644); err != nil {
+			logger.Warn("Failed to mirror image; linking to Flickr", "photo_id", photo.ID, "error", err)
+			return
+		}
+		*field = strings.TrimSuffix(baseURL, "/") + "/" + url.PathEscape(name)
+	})
+	return nil
+}
+
+// mirrorFileName returns the stable local filename for a photo's image: the filename
+// of its Flickr URL, or one built from the photo's ID and secret.
+func mirrorFileName(photo FlickrPhoto, imageURL string) string {
+	if u, err := url.Parse(imageURL); err == nil {
+		if name := path.Base(u.Path); flickrImageName.MatchString(name) && strings.HasPrefix(name, photo.ID+"_") {
+			return name
+		}
+	}
+	return fmt.Sprintf("%s_%s.jpg", photo.ID, photo.Secret)
+}
+
+// downloadFile downloads fileURL to path with the given permissions, unless path already
+// exists. The file is written atomically, so an existing file is always a complete download.
+func downloadFile(client *http.Client, fileURL, path string, perm os.FileMode) error {
+	if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+		return nil
+	}
+
+	resp, err := client.Get(fileURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	return writeFileAtomic(path, perm, func(w io.Writer) error {
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			return fmt.Errorf("failed to download %s: %w", fileURL, err)
+		}
+		return nil
+	})
+}
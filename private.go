@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// feedTokenLength is the length, in hex characters, of the secret token
+// inserted into tokenized feed filenames and URLs.
+const feedTokenLength = 32
+
+// feedToken derives a feed's secret token from the credentials' feed token secret and
+// the feed's absolute output path. The token is stable across runs, so the feed's URL
+// doesn't change, but it can't be guessed without the secret, and feeds written to
+// the same filename in different directories get different tokens.
+func feedToken(creds *Credentials, output string) string {
+	if abs, err := filepath.Abs(output); err == nil {
+		output = abs
+	}
+	mac := hmac.New(sha256.New, []byte(creds.FeedTokenSecret))
+	mac.Write([]byte("flickr-rss feed token\x00"))
+	mac.Write([]byte(filepath.Clean(output)))
+	return hex.EncodeToString(mac.Sum(nil))[:feedTokenLength]
+}
+
+// tokenizeName inserts the token before a filename's extension: "feed.xml" becomes "feed-<token>.xml".
+func tokenizeName(name, token string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "-" + token + ext
+}
+
+// ApplyFeedToken rewrites the feed's output path, and its gallery page, comments feed,
+// and self URL if set, to include the feed's secret token, so a feed published from a
+// web server can only be found by those who are given its URL. It does nothing unless
+// cfg.Tokenize is set.
+func ApplyFeedToken(creds *Credentials, cfg FeedConfig) (FeedConfig, error) {
+	if !cfg.Tokenize {
+		return cfg, nil
+	}
+	if cfg.Output == "" {
+		return cfg, NewUsage("--tokenize requires --output")
+	}
+	if creds.FeedTokenSecret == "" {
+		return cfg, NewUsage("--tokenize requires a feed token secret; add one to the credentials file as feed_token_secret, " +
+			"generating it with 'flickr-rss auth --generate-feed-token-secret', or give it with --feed-token-secret")
+	}
+
+	token := feedToken(creds, cfg.Output)
+	cfg.Output = filepath.Join(filepath.Dir(cfg.Output), tokenizeName(filepath.Base(cfg.Output), token))
+	if cfg.HTML != "" {
+		cfg.HTML = filepath.Join(filepath.Dir(cfg.HTML), tokenizeName(filepath.Base(cfg.HTML), token))
+	}
+	if cfg.CommentsFeed != "" {
+		cfg.CommentsFeed = filepath.Join(filepath.Dir(cfg.CommentsFeed), tokenizeName(filepath.Base(cfg.CommentsFeed), token))
+	}
+
+	if cfg.SelfURL != "" {
+		u, err := url.Parse(cfg.SelfURL)
+		if err != nil {
+			return cfg, NewUsage(fmt.Sprintf("invalid self URL '%s': %s", cfg.SelfURL, err))
+		}
+		dir, name := path.Split(u.Path)
+		if name == "" {
+			return cfg, NewUsage(fmt.Sprintf("self URL '%s' must end in a filename to be tokenized", cfg.SelfURL))
+		}
+		u.Path = dir + tokenizeName(name, token)
+		u.RawPath = ""
+		cfg.SelfURL = u.String()
+	}
+
+	cfg.Logger().Info("Tokenized feed", "path", cfg.Output, "self_url", cfg.SelfURL)
+
+	return cfg, nil
+}
+
+// checkPrivateOutput refuses to write a private feed over an existing world-readable
+// file, which may be somewhere (like a web root) that exposes it publicly.
+func checkPrivateOutput(output string) error {
+	info, err := os.Stat(output)
+	if err != nil {
+		return nil
+	}
+	if info.Mode().Perm()&0004 != 0 {
+		return NewUsage(fmt.Sprintf("refusing to write a feed containing non-public photos to world-readable file %s; "+
+			"remove the file or restrict its permissions, or use --tokenize to publish the feed at an unguessable URL", output))
+	}
+	return nil
+}
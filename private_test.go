@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFeedToken(t *testing.T) {
+	creds := &Credentials{APISecret: "api-secret", FeedTokenSecret: "feed-secret"}
+	token := feedToken(creds, "/var/www/feeds/ff.xml")
+
+	if len(token) != feedTokenLength {
+		t.Errorf("token %q has length %d, want %d", token, len(token), feedTokenLength)
+	}
+	if again := feedToken(creds, "/var/www/feeds/../feeds/ff.xml"); again != token {
+		t.Errorf("token for the same path changed: %q, then %q", token, again)
+	}
+	if other := feedToken(creds, "/var/www/other/ff.xml"); other == token {
+		t.Errorf("feeds with the same filename in different directories got the same token %q", token)
+	}
+	rotated := &Credentials{APISecret: "api-secret", FeedTokenSecret: "another-secret"}
+	if other := feedToken(rotated, "/var/www/feeds/ff.xml"); other == token {
+		t.Errorf("different feed token secrets gave the same token %q", token)
+	}
+	sameAPISecret := &Credentials{APISecret: "other-api-secret", FeedTokenSecret: "feed-secret"}
+	if other := feedToken(sameAPISecret, "/var/www/feeds/ff.xml"); other != token {
+		t.Errorf("token depends on the API secret: %q, then %q", token, other)
+	}
+}
+
+func TestApplyFeedToken(t *testing.T) {
+	creds := &Credentials{APIKey: "key", APISecret: "secret", FeedTokenSecret: "feed-secret"}
+	cfg := FeedConfig{
+		Tokenize:     true,
+		Output:       "/var/www/feeds/ff.xml",
+		HTML:         "/var/www/feeds/ff.html",
+		CommentsFeed: "/var/www/feeds/ff-comments.xml",
+		SelfURL:      "https://example.com/feeds/ff.xml?x=1",
+	}
+
+	got, err := ApplyFeedToken(creds, cfg)
+	if err != nil {
+		t.Fatalf("ApplyFeedToken: %v", err)
+	}
+	token := feedToken(creds, cfg.Output)
+	want := FeedConfig{
+		Tokenize:     true,
+		Output:       "/var/www/feeds/ff-" + token + ".xml",
+		HTML:         "/var/www/feeds/ff-" + token + ".html",
+		CommentsFeed: "/var/www/feeds/ff-comments-" + token + ".xml",
+		SelfURL:      "https://example.com/feeds/ff-" + token + ".xml?x=1",
+	}
+	if got.Output != want.Output || got.HTML != want.HTML || got.CommentsFeed != want.CommentsFeed || got.SelfURL != want.SelfURL {
+		t.Errorf("ApplyFeedToken = %+v, want %+v", got, want)
+	}
+}
+
+func TestApplyFeedTokenRequiresSecret(t *testing.T) {
+	// The secret is never generated implicitly, which would rewrite the credentials file
+	path := filepath.Join(t.TempDir(), "creds.yml")
+	const stored = "# my Flickr app\napi_key: key\napi_secret: secret\n"
+	if err := os.WriteFile(path, []byte(stored), 0600); err != nil {
+		t.Fatal(err)
+	}
+	oldCredsFile := credsFile
+	credsFile = path
+	t.Cleanup(func() { credsFile = oldCredsFile })
+
+	creds, err := loadCredsIfProvided()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ApplyFeedToken(creds, FeedConfig{Tokenize: true, Output: "ff.xml"})
+	if !errors.Is(err, ErrUsage) {
+		t.Errorf("got error %v (class %q), want a usage error", err, ErrorClass(err))
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != stored {
+		t.Errorf("credentials file was changed to %q (error %v)", data, err)
+	}
+}
@@ -1,9 +1,16 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
 	"fmt"
 	"html"
 	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -12,16 +19,51 @@ type RSSFeed struct {
 	Title       string
 	Link        string
 	Description string
+	SelfURL     string
+	HubURL      string
+	Author      string
+	Image       *RSSImage
 	Items       []RSSItem
+
+	// Photos are the photos the items were generated from, in the same order.
+	Photos []FlickrPhoto
+	// Extras are the optional photo details the items include.
+	Extras ItemExtras
+
+	// Private is set when the feed contains non-public photos. It doesn't affect
+	// the feed's content, only how it may be written.
+	Private bool
+	// BuildDate is the feed's lastBuildDate. If zero, the current time is used.
+	BuildDate time.Time
+}
+
+type RSSImage struct {
+	URL   string
+	Title string
+	Link  string
+}
+
+// FeedInfo describes the source of a feed: who it's from and where it lives.
+type FeedInfo struct {
+	// Name is the display name used in the feed title and description.
+	Name string
+	// Link is the Flickr page corresponding to the feed.
+	Link string
+	// SelfURL is the URL the feed itself is published at, if known.
+	SelfURL string
+	// Person is the profile of the user the feed is from, if it's a single user's feed.
+	Person *FlickrPerson
 }
 
 type RSSItem struct {
 	Title       string
 	Link        string
 	Description string
-	Author      string
+	Creator     string
 	PubDate     string
 	GUID        string
+	Categories  []string
+	GeoPoint    string
 	Enclosure   *RSSEnclosure
 }
 
@@ -31,32 +73,56 @@ type RSSEnclosure struct {
 	Length string
 }
 
-func GenerateRSSFeed(photos []FlickrPhoto, username string) *RSSFeed {
+// GenerateRSSFeed builds a feed of the photos. Optional details, such as tags and
+// license, are included only if selected by extras, even if the photos have them.
+func GenerateRSSFeed(photos []FlickrPhoto, info FeedInfo, dateSource DateSource, extras ItemExtras) *RSSFeed {
 	feed := &RSSFeed{
-		Title:       fmt.Sprintf("Flickr Photos from %s", username),
-		Link:        fmt.Sprintf("https://www.flickr.com/people/%s/", username),
-		Description: fmt.Sprintf("Latest photos from Flickr user %s", username),
+		Title:       fmt.Sprintf("Flickr Photos from %s", info.Name),
+		Link:        info.Link,
+		Description: fmt.Sprintf("Latest photos from Flickr user %s", info.Name),
+		SelfURL:     info.SelfURL,
 		Items:       make([]RSSItem, 0, len(photos)),
+		Extras:      extras,
 	}
 
-	for _, photo := range photos {
-		// Use photo owner for link if available, otherwise fallback to feed username
-		linkOwner := username
-		if photo.Owner != "" {
-			linkOwner = photo.Owner
+	if info.Person != nil {
+		feed.Description = personFeedDescription(info.Person)
+		feed.Author = info.Person.DisplayName()
+		feed.Image = &RSSImage{
+			URL:   info.Person.BuddyIconURL(),
+			Title: feed.Title,
+			Link:  feed.Link,
 		}
+	}
 
+	photos = append([]FlickrPhoto(nil), photos...)
+	sortPhotosByDate(photos, dateSource)
+	feed.Photos = photos
+
+	for _, photo := range photos {
 		item := RSSItem{
 			Title:       photo.Title,
-			Link:        fmt.Sprintf("https://www.flickr.com/photos/%s/%s/", linkOwner, photo.ID),
-			Description: generateItemDescription(photo),
-			Author:      photo.Username,
-			PubDate:     formatPubDate(photo.DateTaken),
+			Link:        photoPageURL(photo),
+			Description: generateItemDescription(photo, extras),
+			Creator:     photo.OwnerDisplayName(),
+			PubDate:     photoDate(photo, dateSource).Format(time.RFC1123Z),
 			GUID:        photo.ID,
 		}
+		if extras.Tags {
+			item.Categories = strings.Fields(photo.Tags)
+		}
+		if extras.Geo {
+			item.GeoPoint = geoPoint(photo)
+		}
 
-		// Add enclosure if we have a large URL
-		if photo.URLLarge != "" {
+		// Add enclosure: the video file for videos, otherwise the large image if available
+		if photo.Video != nil {
+			item.Enclosure = &RSSEnclosure{
+				URL:    photo.Video.URL,
+				Type:   "video/mp4",
+				Length: "0",
+			}
+		} else if photo.URLLarge != "" {
 			item.Enclosure = &RSSEnclosure{
 				URL:    photo.URLLarge,
 				Type:   "image/jpeg",
@@ -76,7 +142,27 @@ func GenerateRSSFeed(photos []FlickrPhoto, username string) *RSSFeed {
 	return feed
 }
 
-func generateItemDescription(photo FlickrPhoto) string {
+func personFeedDescription(person *FlickrPerson) string {
+	desc := fmt.Sprintf("Latest photos from Flickr user %s", person.DisplayName())
+	if person.Location != "" {
+		desc += fmt.Sprintf(" in %s", person.Location)
+	}
+	if about := StripHTML(person.Description); about != "" {
+		desc += "\n\n" + about
+	}
+	return desc
+}
+
+// photoPageURL returns the photo's page on Flickr. Without the owner's NSID,
+// it falls back to Flickr's short photo URL, which redirects to the photo page.
+func photoPageURL(photo FlickrPhoto) string {
+	if photo.Owner == "" {
+		return fmt.Sprintf("https://www.flickr.com/photo.gne?id=%s", url.QueryEscape(photo.ID))
+	}
+	return fmt.Sprintf("https://www.flickr.com/photos/%s/%s/", url.PathEscape(photo.Owner), url.PathEscape(photo.ID))
+}
+
+func generateItemDescription(photo FlickrPhoto, extras ItemExtras) string {
 	var desc strings.Builder
 
 	// Use large image for display
@@ -91,104 +177,395 @@ func generateItemDescription(photo FlickrPhoto) string {
 		}
 	}
 
-	desc.WriteString(fmt.Sprintf(`<img src="%s" alt="%s" />`,
-		html.EscapeString(imageURL), html.EscapeString(photo.Title)))
+	if photo.Video != nil {
+		desc.WriteString(fmt.Sprintf(`<video src="%s" poster="%s" controls="controls"`,
+			html.EscapeString(photo.Video.URL), html.EscapeString(imageURL)))
+		if photo.Video.Width > 0 && photo.Video.Height > 0 {
+			desc.WriteString(fmt.Sprintf(` width="%d" height="%d"`, photo.Video.Width, photo.Video.Height))
+		}
+		desc.WriteString(fmt.Sprintf(`><img src="%s" alt="%s" /></video>`,
+			html.EscapeString(imageURL), html.EscapeString(photo.Title)))
+	} else {
+		desc.WriteString(fmt.Sprintf(`<img src="%s" alt="%s" />`,
+			html.EscapeString(imageURL), html.EscapeString(photo.Title)))
+	}
 
 	// Add description if available
 	if photo.Description.Content != "" {
 		desc.WriteString("<br/><br/>")
-		// Don't escape HTML in description content since it may contain valid HTML tags like links
-		// The description will be wrapped in CDATA in the RSS feed
-		desc.WriteString(photo.Description.Content)
+		// Descriptions may contain valid HTML like links, so rather than escaping everything,
+		// reduce them to a safe allowlist of tags and attributes
+		desc.WriteString(SanitizeHTML(photo.Description.Content))
+	}
+
+	if details := generateItemDetails(photo, extras); details != "" {
+		desc.WriteString("<br/><br/>")
+		desc.WriteString(details)
 	}
 
 	return desc.String()
 }
 
-func formatPubDate(dateTaken string) string {
-	// Flickr returns dates in format "2023-07-15 12:34:56"
-	if dateTaken == "" {
-		return time.Now().Format(time.RFC1123Z)
+// generateItemDetails renders the photo's EXIF summary, license, and view count,
+// whichever are selected by extras and available.
+func generateItemDetails(photo FlickrPhoto, extras ItemExtras) string {
+	var lines []string
+
+	if extras.EXIF && !photo.EXIF.IsEmpty() {
+		exif := photo.EXIF
+		for _, field := range []struct{ label, value string }{
+			{"Camera", exif.Camera},
+			{"Lens", exif.Lens},
+			{"Focal length", exif.FocalLength},
+			{"Aperture", exif.Aperture},
+			{"Shutter speed", exif.ShutterSpeed},
+			{"ISO", exif.ISO},
+		} {
+			if field.value != "" {
+				lines = append(lines, fmt.Sprintf("<b>%s:</b> %s", field.label, html.EscapeString(field.value)))
+			}
+		}
+	}
+
+	if extras.License && photo.License != "" {
+		license := licenseFor(string(photo.License))
+		if license.URL != "" {
+			lines = append(lines, fmt.Sprintf(`<b>License:</b> <a href="%s">%s</a>`,
+				html.EscapeString(license.URL), html.EscapeString(license.Name)))
+		} else {
+			lines = append(lines, fmt.Sprintf("<b>License:</b> %s", html.EscapeString(license.Name)))
+		}
+	}
+
+	if extras.Views && photo.Views != "" {
+		lines = append(lines, fmt.Sprintf("<b>Views:</b> %s", html.EscapeString(string(photo.Views))))
+	}
+
+	return strings.Join(lines, "<br/>")
+}
+
+// geoPoint returns the photo's location as a GeoRSS point ("lat lon"), or an
+// empty string if the photo isn't geotagged.
+func geoPoint(photo FlickrPhoto) string {
+	lat, errLat := strconv.ParseFloat(string(photo.Latitude), 64)
+	lon, errLon := strconv.ParseFloat(string(photo.Longitude), 64)
+	if errLat != nil || errLon != nil || (lat == 0 && lon == 0) {
+		return ""
+	}
+	return fmt.Sprintf("%s %s", photo.Latitude, photo.Longitude)
+}
+
+// DateSource selects which Flickr date drives item pubDate and feed ordering.
+type DateSource string
+
+const (
+	DateSourceUploaded DateSource = "uploaded"
+	DateSourceTaken    DateSource = "taken"
+	DateSourceUpdated  DateSource = "updated"
+)
+
+func ParseDateSource(s string) (DateSource, error) {
+	switch ds := DateSource(strings.ToLower(strings.TrimSpace(s))); ds {
+	case DateSourceUploaded, DateSourceTaken, DateSourceUpdated:
+		return ds, nil
+	case "":
+		return DateSourceUploaded, nil
+	default:
+		return "", NewUsage(fmt.Sprintf("invalid date source '%s' (must be one of: uploaded, taken, updated)", s))
+	}
+}
+
+// photoDate returns the photo's date according to source. If that date is missing
+// or unparseable, it falls back to the upload date and finally to the Unix epoch,
+// so that the result is stable from run to run.
+func photoDate(photo FlickrPhoto, source DateSource) time.Time {
+	var t time.Time
+	var ok bool
+	switch source {
+	case DateSourceTaken:
+		t, ok = parseDateTaken(photo.DateTaken)
+	case DateSourceUpdated:
+		t, ok = parseUnixTimestamp(photo.LastUpdate)
+	}
+	if ok {
+		return t
 	}
 
-	// Parse the date
+	if t, ok = parseUnixTimestamp(photo.DateUpload); ok {
+		return t
+	}
+	return time.Unix(0, 0).UTC()
+}
+
+func parseDateTaken(dateTaken string) (time.Time, bool) {
+	// Flickr returns dates taken in format "2023-07-15 12:34:56", with no time zone
+	if dateTaken == "" {
+		return time.Time{}, false
+	}
 	t, err := time.Parse("2006-01-02 15:04:05", dateTaken)
 	if err != nil {
-		return time.Now().Format(time.RFC1123Z)
+		return time.Time{}, false
 	}
+	return t, true
+}
 
-	return t.Format(time.RFC1123Z)
+func parseUnixTimestamp(ts string) (time.Time, bool) {
+	// Flickr returns upload and update dates as Unix timestamps in strings
+	secs, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || secs <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(secs, 0).UTC(), true
 }
 
-func (feed *RSSFeed) WriteXML(w io.Writer) error {
-	// Write XML header
-	if _, err := fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
-<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
-<channel>
-<title>%s</title>
-<link>%s</link>
-<description>%s</description>
-<language>en-us</language>
-<lastBuildDate>%s</lastBuildDate>
-<atom:link href="%s" rel="self" type="application/rss+xml" />
-`,
-		html.EscapeString(feed.Title),
-		html.EscapeString(feed.Link),
-		html.EscapeString(feed.Description),
-		time.Now().Format(time.RFC1123Z),
-		html.EscapeString(feed.Link)); err != nil {
-		return err
+// sortPhotosByDate orders photos newest-first according to source.
+func sortPhotosByDate(photos []FlickrPhoto, source DateSource) {
+	sort.SliceStable(photos, func(i, j int) bool {
+		return photoDate(photos[i], source).After(photoDate(photos[j], source))
+	})
+}
+
+const (
+	atomNamespace   = "http://www.w3.org/2005/Atom"
+	dcNamespace     = "http://purl.org/dc/elements/1.1/"
+	itunesNamespace = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+	georssNamespace = "http://www.georss.org/georss"
+)
+
+// rssDocument and the types below mirror the RSS 2.0 document structure for
+// marshalling with encoding/xml.
+type rssDocument struct {
+	XMLName  xml.Name   `xml:"rss"`
+	Version  string     `xml:"version,attr"`
+	AtomNS   string     `xml:"xmlns:atom,attr"`
+	DCNS     string     `xml:"xmlns:dc,attr"`
+	ItunesNS string     `xml:"xmlns:itunes,attr"`
+	GeoRSSNS string     `xml:"xmlns:georss,attr"`
+	Channel  rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string       `xml:"title"`
+	Link          string       `xml:"link"`
+	Description   string       `xml:"description"`
+	Language      string       `xml:"language"`
+	LastBuildDate string       `xml:"lastBuildDate"`
+	Image         *rssImageEl  `xml:"image,omitempty"`
+	ItunesAuthor  string       `xml:"itunes:author,omitempty"`
+	ItunesImage   *itunesImage `xml:"itunes:image,omitempty"`
+	ItunesOwner   *itunesOwner `xml:"itunes:owner,omitempty"`
+	AtomLinks     []atomLink   `xml:"atom:link"`
+	Items         []rssItemEl  `xml:"item"`
+}
+
+type rssImageEl struct {
+	URL   string `xml:"url"`
+	Title string `xml:"title"`
+	Link  string `xml:"link"`
+}
+
+type itunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type itunesOwner struct {
+	Name string `xml:"itunes:name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type rssItemEl struct {
+	Title       string          `xml:"title"`
+	Link        string          `xml:"link"`
+	Description cdataText       `xml:"description"`
+	Creator     string          `xml:"dc:creator,omitempty"`
+	PubDate     string          `xml:"pubDate,omitempty"`
+	GUID        rssGUID         `xml:"guid"`
+	Categories  []string        `xml:"category"`
+	GeoPoint    string          `xml:"georss:point,omitempty"`
+	Enclosure   *rssEnclosureEl `xml:"enclosure,omitempty"`
+}
+
+type cdataText struct {
+	Text string `xml:",cdata"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink string `xml:"isPermaLink,attr"`
+}
+
+type rssEnclosureEl struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// toDocument builds the feed's XML document. A zero buildDate leaves lastBuildDate empty.
+func (feed *RSSFeed) toDocument(buildDate time.Time) *rssDocument {
+	doc := &rssDocument{
+		Version:  "2.0",
+		AtomNS:   atomNamespace,
+		DCNS:     dcNamespace,
+		ItunesNS: itunesNamespace,
+		GeoRSSNS: georssNamespace,
+		Channel: rssChannel{
+			Title:       xmlSafe(feed.Title),
+			Link:        xmlSafe(feed.Link),
+			Description: xmlSafe(feed.Description),
+			Language:    "en-us",
+			Items:       make([]rssItemEl, 0, len(feed.Items)),
+		},
 	}
 
-	// Write items
-	for _, item := range feed.Items {
-		if _, err := fmt.Fprintf(w, `
-<item>
-<title>%s</title>
-<link>%s</link>`,
-			html.EscapeString(item.Title),
-			html.EscapeString(item.Link)); err != nil {
-			return err
-		}
+	if !buildDate.IsZero() {
+		doc.Channel.LastBuildDate = buildDate.Format(time.RFC1123Z)
+	}
 
-		// Add author if present
-		if item.Author != "" {
-			if _, err := fmt.Fprintf(w, `
-<author>%s</author>`, html.EscapeString(item.Author)); err != nil {
-				return err
-			}
+	if feed.Image != nil {
+		doc.Channel.Image = &rssImageEl{
+			URL:   xmlSafe(feed.Image.URL),
+			Title: xmlSafe(feed.Image.Title),
+			Link:  xmlSafe(feed.Image.Link),
 		}
+		doc.Channel.ItunesImage = &itunesImage{Href: xmlSafe(feed.Image.URL)}
+	}
 
-		if _, err := fmt.Fprintf(w, `
-<description><![CDATA[%s]]></description>
-<pubDate>%s</pubDate>
-<guid>%s</guid>`,
-			item.Description, // HTML content preserved, wrapped in CDATA
-			item.PubDate,
-			html.EscapeString(item.GUID)); err != nil {
-			return err
-		}
+	if feed.Author != "" {
+		doc.Channel.ItunesAuthor = xmlSafe(feed.Author)
+		doc.Channel.ItunesOwner = &itunesOwner{Name: xmlSafe(feed.Author)}
+	}
 
-		// Add enclosure if present
+	if feed.SelfURL != "" {
+		doc.Channel.AtomLinks = append(doc.Channel.AtomLinks, atomLink{
+			Href: xmlSafe(feed.SelfURL),
+			Rel:  "self",
+			Type: "application/rss+xml",
+		})
+	}
+	if feed.HubURL != "" {
+		doc.Channel.AtomLinks = append(doc.Channel.AtomLinks, atomLink{
+			Href: xmlSafe(feed.HubURL),
+			Rel:  "hub",
+		})
+	}
+
+	for _, item := range feed.Items {
+		el := rssItemEl{
+			Title:       xmlSafe(item.Title),
+			Link:        xmlSafe(item.Link),
+			Description: cdataText{Text: xmlSafe(item.Description)},
+			Creator:     xmlSafe(item.Creator),
+			PubDate:     item.PubDate,
+			GUID: rssGUID{
+				Value:       xmlSafe(item.GUID),
+				IsPermaLink: "false",
+			},
+			GeoPoint: item.GeoPoint,
+		}
+		for _, category := range item.Categories {
+			el.Categories = append(el.Categories, xmlSafe(category))
+		}
 		if item.Enclosure != nil {
-			if _, err := fmt.Fprintf(w, `
-<enclosure url="%s" type="%s" length="%s" />`,
-				html.EscapeString(item.Enclosure.URL),
-				html.EscapeString(item.Enclosure.Type),
-				html.EscapeString(item.Enclosure.Length)); err != nil {
-				return err
+			el.Enclosure = &rssEnclosureEl{
+				URL:    xmlSafe(item.Enclosure.URL),
+				Type:   item.Enclosure.Type,
+				Length: item.Enclosure.Length,
 			}
 		}
+		doc.Channel.Items = append(doc.Channel.Items, el)
+	}
 
-		if _, err := fmt.Fprintf(w, "\n</item>"); err != nil {
-			return err
-		}
+	return doc
+}
+
+func (feed *RSSFeed) WriteXML(w io.Writer) error {
+	hash, err := feed.ContentHash()
+	if err != nil {
+		return err
+	}
+
+	buildDate := feed.BuildDate
+	if buildDate.IsZero() {
+		buildDate = time.Now()
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "<!-- %s %s -->\n", contentHashMarker, hash); err != nil {
+		return err
 	}
 
-	// Close channel and rss tags
-	if _, err := fmt.Fprintf(w, "\n</channel>\n</rss>\n"); err != nil {
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed.toDocument(buildDate)); err != nil {
 		return err
 	}
 
-	return nil
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// contentHashMarker precedes the content hash recorded in a comment at the top of each feed.
+const contentHashMarker = "flickr-rss content-hash:"
+
+// ContentHash returns a hash of everything in the feed except its lastBuildDate, so
+// that feeds with the same channel metadata and items have the same hash.
+func (feed *RSSFeed) ContentHash() (string, error) {
+	h := sha256.New()
+	if err := xml.NewEncoder(h).Encode(feed.toDocument(time.Time{})); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readFeedContentHash returns the content hash recorded in an existing feed file,
+// or an empty string if the file doesn't exist or has no recorded hash.
+func readFeedContentHash(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	// The hash comment immediately follows the XML declaration
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	text := string(head[:n])
+
+	i := strings.Index(text, "<!-- "+contentHashMarker+" ")
+	if i < 0 {
+		return ""
+	}
+	text = text[i+len("<!-- "+contentHashMarker+" "):]
+	end := strings.Index(text, " -->")
+	if end < 0 {
+		return ""
+	}
+	return text[:end]
+}
+
+// xmlSafe removes invalid UTF-8 and characters that are not allowed anywhere
+// in an XML 1.0 document, such as most ASCII control characters.
+func xmlSafe(s string) string {
+	s = strings.ToValidUTF8(s, "")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == 0x09 || r == 0x0A || r == 0x0D:
+			return r
+		case r >= 0x20 && r <= 0xD7FF:
+			return r
+		case r >= 0xE000 && r <= 0xFFFD:
+			return r
+		case r >= 0x10000 && r <= 0x10FFFF:
+			return r
+		default:
+			return -1
+		}
+	}, s)
 }
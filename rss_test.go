@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parsedFeed is the subset of a written feed the tests inspect.
+type parsedFeed struct {
+	Channel struct {
+		Title         string `xml:"title"`
+		Description   string `xml:"description"`
+		LastBuildDate string `xml:"lastBuildDate"`
+		AtomLinks     []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"http://www.w3.org/2005/Atom link"`
+		Items []struct {
+			Title       string `xml:"title"`
+			Description string `xml:"description"`
+			Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+			Author      string `xml:"author"`
+			PubDate     string `xml:"pubDate"`
+			GUID        struct {
+				Value       string `xml:",chardata"`
+				IsPermaLink string `xml:"isPermaLink,attr"`
+			} `xml:"guid"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+func testFeed(selfURL string) *RSSFeed {
+	photo := FlickrPhoto{
+		ID:         "12345",
+		Title:      "Sunset\x00 over\x1b the bay",
+		DateUpload: "1700000000",
+		Owner:      "99999@N00",
+		OwnerName:  "Jane\x08 Doe",
+		URLLarge:   "https://live.staticflickr.com/1/12345_abc_b.jpg",
+	}
+	photo.Description.Content = "Looks like ]]> and <b>bold</b>\x0c text"
+	feed := GenerateRSSFeed([]FlickrPhoto{photo}, FeedInfo{
+		Name:    "Jane\x01 Doe",
+		Link:    "https://www.flickr.com/photos/99999@N00/",
+		SelfURL: selfURL,
+	}, DateSourceUploaded, ItemExtras{})
+	feed.BuildDate = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	return feed
+}
+
+func writeTestFeed(t *testing.T, feed *RSSFeed) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := feed.WriteXML(&buf); err != nil {
+		t.Fatalf("WriteXML: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func parseTestFeed(t *testing.T, data []byte) parsedFeed {
+	t.Helper()
+	var parsed parsedFeed
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("parsing feed: %v\n%s", err, data)
+	}
+	if len(parsed.Channel.Items) != 1 {
+		t.Fatalf("got %d items, want 1", len(parsed.Channel.Items))
+	}
+	return parsed
+}
+
+func TestWriteXMLWellFormed(t *testing.T) {
+	data := writeTestFeed(t, testFeed("https://example.com/feed.xml"))
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		_, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("feed is not well-formed XML: %v\n%s", err, data)
+		}
+	}
+}
+
+func TestWriteXMLStripsControlCharacters(t *testing.T) {
+	data := writeTestFeed(t, testFeed(""))
+
+	for _, c := range data {
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' {
+			t.Fatalf("feed contains control character %#x:\n%s", c, data)
+		}
+	}
+
+	parsed := parseTestFeed(t, data)
+	if got, want := parsed.Channel.Title, "Flickr Photos from Jane Doe"; got != want {
+		t.Errorf("channel title = %q, want %q", got, want)
+	}
+	item := parsed.Channel.Items[0]
+	if got, want := item.Title, "Sunset over the bay"; got != want {
+		t.Errorf("item title = %q, want %q", got, want)
+	}
+	if got, want := item.Creator, "Jane Doe"; got != want {
+		t.Errorf("item creator = %q, want %q", got, want)
+	}
+}
+
+func TestWriteXMLDescriptionWithCDATAEnd(t *testing.T) {
+	feed := testFeed("")
+	feed.Items[0].Description = `<p>a]]>b</p><![CDATA[c]]>`
+	parsed := parseTestFeed(t, writeTestFeed(t, feed))
+
+	if got, want := parsed.Channel.Items[0].Description, feed.Items[0].Description; got != want {
+		t.Errorf("item description = %q, want %q", got, want)
+	}
+}
+
+func TestWriteXMLUsesDCCreator(t *testing.T) {
+	data := writeTestFeed(t, testFeed(""))
+
+	if bytes.Contains(data, []byte("<author>")) {
+		t.Errorf("feed has an <author> element, which RSS requires to be an email address:\n%s", data)
+	}
+	parsed := parseTestFeed(t, data)
+	if item := parsed.Channel.Items[0]; item.Creator == "" || item.Author != "" {
+		t.Errorf("item creator = %q, author = %q; want only dc:creator", item.Creator, item.Author)
+	}
+}
+
+func TestWriteXMLGUIDIsNotPermaLink(t *testing.T) {
+	parsed := parseTestFeed(t, writeTestFeed(t, testFeed("")))
+
+	guid := parsed.Channel.Items[0].GUID
+	if guid.Value != "12345" || guid.IsPermaLink != "false" {
+		t.Errorf("guid = %q, isPermaLink = %q; want %q, %q", guid.Value, guid.IsPermaLink, "12345", "false")
+	}
+}
+
+func TestWriteXMLDates(t *testing.T) {
+	parsed := parseTestFeed(t, writeTestFeed(t, testFeed("")))
+
+	for _, tt := range []struct {
+		name, value string
+		want        time.Time
+	}{
+		{"lastBuildDate", parsed.Channel.LastBuildDate, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{"pubDate", parsed.Channel.Items[0].PubDate, time.Unix(1700000000, 0)},
+	} {
+		got, err := time.Parse(time.RFC1123Z, tt.value)
+		if err != nil {
+			t.Errorf("%s %q is not an RFC 822 date: %v", tt.name, tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWriteXMLSelfLink(t *testing.T) {
+	tests := []struct {
+		name    string
+		selfURL string
+	}{
+		{"with self URL", "https://example.com/feed.xml"},
+		{"without self URL", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed := parseTestFeed(t, writeTestFeed(t, testFeed(tt.selfURL)))
+
+			var selfLinks []string
+			for _, link := range parsed.Channel.AtomLinks {
+				if link.Rel == "self" {
+					selfLinks = append(selfLinks, link.Href)
+				}
+			}
+			switch {
+			case tt.selfURL == "" && len(selfLinks) != 0:
+				t.Errorf("got self links %q, want none", selfLinks)
+			case tt.selfURL != "" && (len(selfLinks) != 1 || selfLinks[0] != tt.selfURL):
+				t.Errorf("got self links %q, want [%q]", selfLinks, tt.selfURL)
+			}
+		})
+	}
+}
+
+func TestGenerateRSSFeedExtras(t *testing.T) {
+	// Filters may fetch tags and license even when they aren't to be included
+	photo := FlickrPhoto{ID: "1", Tags: "sunset bay", License: "4", Views: "10", Latitude: "37.8", Longitude: "-122.4"}
+
+	tests := []struct {
+		name           string
+		extras         ItemExtras
+		wantCategories int
+		wantGeo        bool
+		wantLicense    bool
+		wantViews      bool
+	}{
+		{"none requested", ItemExtras{}, 0, false, false, false},
+		{"tags and license requested", ItemExtras{Tags: true, License: true}, 2, false, true, false},
+		{"all requested", ItemExtras{Tags: true, Geo: true, License: true, Views: true}, 2, true, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := GenerateRSSFeed([]FlickrPhoto{photo}, FeedInfo{Name: "x"}, DateSourceUploaded, tt.extras).Items[0]
+			if len(item.Categories) != tt.wantCategories {
+				t.Errorf("categories = %q, want %d", item.Categories, tt.wantCategories)
+			}
+			if (item.GeoPoint != "") != tt.wantGeo {
+				t.Errorf("geo point = %q, want present: %v", item.GeoPoint, tt.wantGeo)
+			}
+			if strings.Contains(item.Description, "License:") != tt.wantLicense {
+				t.Errorf("description %q has license: %v, want %v", item.Description, !tt.wantLicense, tt.wantLicense)
+			}
+			if strings.Contains(item.Description, "Views:") != tt.wantViews {
+				t.Errorf("description %q has views: %v, want %v", item.Description, !tt.wantViews, tt.wantViews)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"html"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// allowedTags maps each HTML tag permitted in sanitized output to the
+// attributes it may carry.
+var allowedTags = map[string][]string{
+	"a":          {"href", "title"},
+	"abbr":       {"title"},
+	"b":          nil,
+	"blockquote": {"cite"},
+	"br":         nil,
+	"cite":       nil,
+	"code":       nil,
+	"del":        nil,
+	"em":         nil,
+	"hr":         nil,
+	"i":          nil,
+	"ins":        nil,
+	"li":         nil,
+	"ol":         nil,
+	"p":          nil,
+	"pre":        nil,
+	"q":          {"cite"},
+	"s":          nil,
+	"small":      nil,
+	"strike":     nil,
+	"strong":     nil,
+	"sub":        nil,
+	"sup":        nil,
+	"u":          nil,
+	"ul":         nil,
+}
+
+// voidTags are allowed tags that never have a closing tag.
+var voidTags = map[string]bool{
+	"br": true,
+	"hr": true,
+}
+
+// droppedContentTags are tags whose entire content is removed, not just the tags themselves.
+var droppedContentTags = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"iframe":   true,
+	"object":   true,
+	"embed":    true,
+	"noscript": true,
+	"template": true,
+	"textarea": true,
+	"select":   true,
+	"svg":      true,
+	"math":     true,
+	"head":     true,
+	"title":    true,
+}
+
+// urlAttrs are attributes whose values are URLs and must use a safe scheme.
+var urlAttrs = map[string]bool{
+	"href": true,
+	"cite": true,
+}
+
+type htmlAttr struct {
+	name  string
+	value string
+}
+
+// SanitizeHTML reduces untrusted HTML (such as a Flickr photo description) to an
+// allowlisted subset of tags and attributes. Disallowed tags are removed while their
+// text content is kept, except for tags like <script> whose content is dropped entirely.
+// Text and attribute values are re-escaped, and unclosed tags are closed.
+func SanitizeHTML(s string) string {
+	var out strings.Builder
+	var open []string
+	i := 0
+
+	for i < len(s) {
+		lt := strings.IndexByte(s[i:], '<')
+		if lt < 0 {
+			out.WriteString(escapeText(s[i:]))
+			break
+		}
+		out.WriteString(escapeText(s[i : i+lt]))
+		i += lt
+
+		rest := s[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				i = len(s)
+			} else {
+				i += 4 + end + 3
+			}
+			continue
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			i = skipPast(s, i, '>')
+			continue
+		case len(rest) > 2 && rest[1] == '/' && isASCIILetter(rest[2]):
+			name, _, _, next := parseTag(s, i+2)
+			i = next
+			open = closeTag(&out, open, name)
+			continue
+		case len(rest) > 1 && isASCIILetter(rest[1]):
+			name, attrs, selfClosing, next := parseTag(s, i+1)
+			i = next
+			if droppedContentTags[name] {
+				if !selfClosing {
+					i = skipElementContent(s, i, name)
+				}
+				continue
+			}
+			allowedAttrs, ok := allowedTags[name]
+			if !ok {
+				continue
+			}
+			writeStartTag(&out, name, attrs, allowedAttrs)
+			if !voidTags[name] && !selfClosing {
+				open = append(open, name)
+			}
+			continue
+		default:
+			out.WriteString("&lt;")
+			i++
+		}
+	}
+
+	for j := len(open) - 1; j >= 0; j-- {
+		out.WriteString("</" + open[j] + ">")
+	}
+
+	return out.String()
+}
+
+// StripHTML removes all markup from s, returning plain (unescaped) text.
+func StripHTML(s string) string {
+	var out strings.Builder
+	i := 0
+	for i < len(s) {
+		lt := strings.IndexByte(s[i:], '<')
+		if lt < 0 {
+			out.WriteString(s[i:])
+			break
+		}
+		out.WriteString(s[i : i+lt])
+		i += lt
+
+		rest := s[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				i = len(s)
+			} else {
+				i += 4 + end + 3
+			}
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			i = skipPast(s, i, '>')
+		case len(rest) > 2 && rest[1] == '/' && isASCIILetter(rest[2]):
+			_, _, _, i = parseTag(s, i+2)
+		case len(rest) > 1 && isASCIILetter(rest[1]):
+			name, _, selfClosing, next := parseTag(s, i+1)
+			i = next
+			if droppedContentTags[name] && !selfClosing {
+				i = skipElementContent(s, i, name)
+			}
+		default:
+			out.WriteByte('<')
+			i++
+		}
+	}
+	return strings.TrimSpace(html.UnescapeString(out.String()))
+}
+
+func escapeText(s string) string {
+	return html.EscapeString(html.UnescapeString(s))
+}
+
+func writeStartTag(out *strings.Builder, name string, attrs []htmlAttr, allowedAttrs []string) {
+	out.WriteString("<" + name)
+	seen := make(map[string]bool)
+	for _, attr := range attrs {
+		if seen[attr.name] || !slices.Contains(allowedAttrs, attr.name) {
+			continue
+		}
+		value := html.UnescapeString(attr.value)
+		if urlAttrs[attr.name] && !isSafeURL(value) {
+			continue
+		}
+		seen[attr.name] = true
+		out.WriteString(" " + attr.name + `="` + html.EscapeString(value) + `"`)
+	}
+	if voidTags[name] {
+		out.WriteString(" />")
+	} else {
+		out.WriteString(">")
+	}
+}
+
+// closeTag writes closing tags for name and anything opened after it, if name is currently open.
+func closeTag(out *strings.Builder, open []string, name string) []string {
+	for j := len(open) - 1; j >= 0; j-- {
+		if open[j] != name {
+			continue
+		}
+		for k := len(open) - 1; k >= j; k-- {
+			out.WriteString("</" + open[k] + ">")
+		}
+		return open[:j]
+	}
+	return open
+}
+
+// parseTag parses a tag name and its attributes starting at s[i], just after
+// "<" or "</". It returns the lowercased name, the attributes, whether the tag
+// is self-closing, and the index just past the closing ">".
+func parseTag(s string, i int) (string, []htmlAttr, bool, int) {
+	start := i
+	for i < len(s) && isTagNameChar(s[i]) {
+		i++
+	}
+	name := strings.ToLower(s[start:i])
+
+	var attrs []htmlAttr
+	selfClosing := false
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == '>':
+			return name, attrs, selfClosing, i + 1
+		case c == '/':
+			selfClosing = true
+			i++
+			continue
+		case isHTMLSpace(c):
+			i++
+			continue
+		}
+		selfClosing = false
+
+		attrStart := i
+		for i < len(s) && !isHTMLSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		attrName := strings.ToLower(s[attrStart:i])
+		for i < len(s) && isHTMLSpace(s[i]) {
+			i++
+		}
+
+		value := ""
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isHTMLSpace(s[i]) {
+				i++
+			}
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				quote := s[i]
+				i++
+				valueStart := i
+				for i < len(s) && s[i] != quote {
+					i++
+				}
+				value = s[valueStart:i]
+				if i < len(s) {
+					i++
+				}
+			} else {
+				valueStart := i
+				for i < len(s) && !isHTMLSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				value = s[valueStart:i]
+			}
+		}
+		if attrName != "" {
+			attrs = append(attrs, htmlAttr{name: attrName, value: value})
+		}
+	}
+	return name, attrs, selfClosing, len(s)
+}
+
+// skipElementContent returns the index just past the closing tag for name,
+// or len(s) if the element is never closed.
+func skipElementContent(s string, i int, name string) int {
+	closing := "</" + name
+	for {
+		idx := strings.IndexByte(s[i:], '<')
+		if idx < 0 {
+			return len(s)
+		}
+		i += idx
+		// Compare in place rather than lowercasing s, which can change its length
+		if hasPrefixFoldASCII(s[i:], closing) {
+			i += len(closing)
+			if i >= len(s) || !isTagNameChar(s[i]) {
+				return skipPast(s, i, '>')
+			}
+			continue
+		}
+		i++
+	}
+}
+
+// hasPrefixFoldASCII reports whether s begins with prefix, ignoring the case of ASCII
+// letters only. prefix must be lowercase.
+func hasPrefixFoldASCII(s, prefix string) bool {
+	if len(s) < len(prefix) {
+		return false
+	}
+	for j := 0; j < len(prefix); j++ {
+		c := s[j]
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		if c != prefix[j] {
+			return false
+		}
+	}
+	return true
+}
+
+func skipPast(s string, i int, c byte) int {
+	idx := strings.IndexByte(s[i:], c)
+	if idx < 0 {
+		return len(s)
+	}
+	return i + idx + 1
+}
+
+func isSafeURL(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto":
+		return true
+	default:
+		return false
+	}
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isTagNameChar(c byte) bool {
+	return isASCIILetter(c) || (c >= '0' && c <= '9')
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,89 @@
+package main
+
+import "testing"
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain text", "Sunset over the bay", "Sunset over the bay"},
+		{"allowed tags", "<b>bold</b> and <i>italic</i>", "<b>bold</b> and <i>italic</i>"},
+		{"text is escaped", "fish & chips < 5", "fish &amp; chips &lt; 5"},
+		{"entities are not double-escaped", "fish &amp; chips", "fish &amp; chips"},
+		{"safe link", `<a href="https://example.com/?a=1&amp;b=2">x</a>`, `<a href="https://example.com/?a=1&amp;b=2">x</a>`},
+		{"relative link", `<a href="/photos/me/">x</a>`, `<a href="/photos/me/">x</a>`},
+		{"javascript link", `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"uppercase javascript link", `<a href="JavaScript:alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript link with leading space", `<a href=" javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"decimal entity-encoded javascript link", `<a href="&#106;avascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"hex entity-encoded javascript link", `<a href="&#x6A;&#x61;vascript&colon;alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript link with embedded tab", "<a href=\"java\tscript:alert(1)\">x</a>", `<a>x</a>`},
+		{"data link", `<a href="data:text/html;base64,PHNjcmlwdD4=">x</a>`, `<a>x</a>`},
+		{"javascript cite", `<blockquote cite="javascript:alert(1)">q</blockquote>`, `<blockquote>q</blockquote>`},
+		{"event handler attribute", `<b onclick="alert(1)">x</b>`, `<b>x</b>`},
+		{"unquoted event handler attribute", `<a href=/x onmouseover=alert(1)>x</a>`, `<a href="/x">x</a>`},
+		{"event handler on disallowed tag", `<img src=x onerror="alert(1)">`, ``},
+		{"style attribute", `<p style="color:red">x</p>`, `<p>x</p>`},
+		{"duplicate attribute", `<a href="/a" href="javascript:alert(1)">x</a>`, `<a href="/a">x</a>`},
+		{"attribute value is escaped", `<a title='say "hi" <b>'>x</a>`, `<a title="say &#34;hi&#34; &lt;b&gt;">x</a>`},
+		{"unclosed tag", "<b>bold", "<b>bold</b>"},
+		{"unclosed nested tags", "<p><b><i>x", "<p><b><i>x</i></b></p>"},
+		{"misnested tags", "<b><i>x</b>y</i>", "<b><i>x</i></b>y"},
+		{"stray closing tag", "x</b>y", "xy"},
+		{"void tags", "a<br>b<hr/>c", "a<br />b<hr />c"},
+		{"unterminated tag", `x<b title="y`, "x<b></b>"},
+		{"script content is dropped", "a<script>alert(1)</script>b", "ab"},
+		{"script with markup inside", "a<script>document.write('<b>x</b>')</script>b", "ab"},
+		{"uppercase script", "a<SCRIPT>alert(1)</SCRIPT>b", "ab"},
+		{"unclosed script", "a<script>alert(1)", "a"},
+		{"script closing tag with attributes", "a<script>alert(1)</script foo>b", "ab"},
+		{"svg content is dropped", `a<svg onload="alert(1)"><script>alert(1)</script><text>x</text></svg>b`, "ab"},
+		{"style content is dropped", "a<style>body{display:none}</style>b", "ab"},
+		{"iframe content is dropped", `a<iframe src="https://evil.example/">x</iframe>b`, "ab"},
+		{"disallowed tag keeps text", "<span>kept</span>", "kept"},
+		{"comment", "a<!-- <script>alert(1)</script> -->b", "ab"},
+		{"unterminated comment", "a<!-- b", "a"},
+		{"doctype", "<!DOCTYPE html>x", "x"},
+		{"script after text that changes length when lowercased", "ẞẞẞẞ<script>x", "ẞẞẞẞ"},
+		{"closed script after text that changes length when lowercased", "ẞẞẞẞ<script>x</script>after", "ẞẞẞẞafter"},
+		{"style containing dotted capital I", "<style>İ</style>visible <b>x</b>", "visible <b>x</b>"},
+		{"script containing dotted capital I", "a<script>İİİ</script>b<i>c</i>", "ab<i>c</i>"},
+		{"closing tag with non-ASCII case fold", "a<script>x</ſcript>y</script>b", "ab"},
+		{"lone less-than", "1 < 2 <3", "1 &lt; 2 &lt;3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeHTML(tt.in); got != tt.want {
+				t.Errorf("SanitizeHTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain text", "Sunset", "Sunset"},
+		{"tags are removed", "<b>bold</b> <a href=\"/x\">link</a>", "bold link"},
+		{"entities are decoded", "fish &amp; chips", "fish & chips"},
+		{"script content is dropped", "a<script>alert('<b>')</script>b", "ab"},
+		{"svg content is dropped", "a<svg><text>x</text></svg>b", "ab"},
+		{"style containing dotted capital I", "<style>İ</style>visible", "visible"},
+		{"comment", "a<!-- x -->b", "ab"},
+		{"whitespace is trimmed", "  <p>x</p>  ", "x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripHTML(tt.in); got != tt.want {
+				t.Errorf("StripHTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strconv"
+)
+
+// videoConcurrency bounds the number of concurrent flickr.photos.getSizes requests.
+const videoConcurrency = 4
+
+// PhotoVideo is a playable MP4 source for a Flickr video.
+type PhotoVideo struct {
+	URL    string
+	Width  int
+	Height int
+}
+
+// IsVideo reports whether the item is a video that has finished processing.
+// Videos still being processed are treated as photos, using their still frame.
+func (p FlickrPhoto) IsVideo() bool {
+	return p.Media == "video" && (p.MediaStatus == "" || p.MediaStatus == "ready")
+}
+
+// fetchVideoSources populates the Video field of each video in photos, making up to
+// videoConcurrency requests at a time. Videos whose sources can't be fetched are
+// left as still images.
+func fetchVideoSources(client *FlickrClient, photos []FlickrPhoto) {
+	var videos []int
+	for i := range photos {
+		if photos[i].IsVideo() {
+			videos = append(videos, i)
+		}
+	}
+
+	forEachConcurrently(len(videos), videoConcurrency, func(j int) {
+		photo := &photos[videos[j]]
+		sizes, err := client.GetPhotoSizes(photo.ID)
+		if err != nil {
+			client.logger.Warn("Failed to get video sources; using still image", "photo_id", photo.ID, "error", err)
+			return
+		}
+		photo.Video = bestVideoSource(sizes)
+	})
+}
+
+// bestVideoSource picks the widest playable MP4 source from a video's sizes.
+func bestVideoSource(sizes []FlickrSize) *PhotoVideo {
+	var best *PhotoVideo
+	for _, size := range sizes {
+		// "Video Player" is an embeddable player, not a video file, and
+		// "Video Original" may be in any format.
+		if size.Media != "video" || size.Label == "Video Player" || size.Label == "Video Original" || size.Source == "" {
+			continue
+		}
+		width, _ := strconv.Atoi(string(size.Width))
+		height, _ := strconv.Atoi(string(size.Height))
+		if best == nil || width > best.Width {
+			best = &PhotoVideo{
+				URL:    size.Source,
+				Width:  width,
+				Height: height,
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// PublishToHub notifies a WebSub hub that the feed at topicURL has new content, so the
+// hub fetches it and distributes it to subscribers.
+// See https://www.w3.org/TR/websub/#publishing and https://pubsubhubbub.github.io/PubSubHubbub/pubsubhubbub-core-0.4.html#rfc.section.10
+func PublishToHub(hubURL, topicURL string) error {
+	form := url.Values{}
+	form.Set("hub.mode", "publish")
+	form.Set("hub.url", topicURL)
+
+	req, err := http.NewRequest(http.MethodPost, hubURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("invalid WebSub hub URL '%s': %w", hubURL, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "flickr-rss/"+version)
+
+	client := &http.Client{Timeout: hookTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("WebSub publish request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := strings.TrimSpace(string(body))
+		if msg != "" {
+			return fmt.Errorf("WebSub hub returned status %d: %s", resp.StatusCode, msg)
+		}
+		return fmt.Errorf("WebSub hub returned status %d", resp.StatusCode)
+	}
+	return nil
+}